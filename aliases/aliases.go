@@ -0,0 +1,389 @@
+package aliases
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	bolt "github.com/coreos/bbolt"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	bucketName = "Aliases"
+)
+
+// LockTimeout is how long opening a bolt db waits for another process to let
+// go of it before failing with ErrDBLocked.
+var LockTimeout = time.Second
+
+////////////////////////////////////////////////////////////////////////////////
+
+// MacIface holds a MAC Address to wake up, along with an optionally specified
+// default interface to use when typically waking up said interface. The
+// optional Host is the IP address or host name the machine answers on once
+// it is up, which the route to it is picked from.
+type MacIface struct {
+	Mac   string `json:"mac"`
+	Iface string `json:"iface"`
+	Host  string `json:"host,omitempty"`
+}
+
+// DecodeToMacIface takes a byte buffer and converts decodes it using the gob
+// package to a MacIface entry.
+func DecodeToMacIface(buf *bytes.Buffer) (MacIface, error) {
+	var entry MacIface
+	decoder := gob.NewDecoder(buf)
+	err := decoder.Decode(&entry)
+	return entry, err
+}
+
+// EncodeFromMacIface takes a MAC and an Iface and encodes a gob with a MacIface
+// entry.
+func EncodeFromMacIface(mac, iface string) (*bytes.Buffer, error) {
+	return encodeMacIface(MacIface{Mac: mac, Iface: iface})
+}
+
+// encodeMacIface gob encodes a single `entry`. Older versions ignore the
+// fields they do not know about, like the Host.
+func encodeMacIface(entry MacIface) (*bytes.Buffer, error) {
+	buf := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buf).Encode(entry)
+	return buf, err
+}
+
+// DecodeToMacIfaces decodes all of the pairs stored for an alias. Entries
+// written before an alias could hold several pairs are a single gob encoded
+// MacIface, which is returned as a one element slice.
+func DecodeToMacIfaces(buf *bytes.Buffer) ([]MacIface, error) {
+	bs := buf.Bytes()
+
+	var entries []MacIface
+	err := gob.NewDecoder(bytes.NewBuffer(bs)).Decode(&entries)
+	if err == nil && len(entries) > 0 {
+		return entries, nil
+	}
+
+	entry, serr := DecodeToMacIface(bytes.NewBuffer(bs))
+	if serr != nil {
+		if err == nil {
+			err = fmt.Errorf("alias has no mac addresses")
+		}
+		return nil, err
+	}
+	return []MacIface{entry}, nil
+}
+
+// EncodeFromMacIfaces encodes all of the pairs of an alias. A single pair is
+// encoded exactly like EncodeFromMacIface does, so that older versions can
+// still read aliases with just one MAC address.
+func EncodeFromMacIfaces(entries []MacIface) (*bytes.Buffer, error) {
+	if len(entries) == 1 {
+		return encodeMacIface(entries[0])
+	}
+	buf := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buf).Encode(entries)
+	return buf, err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Aliases is the bolt backed Store. It holds a pointer to a mutex which will be
+// acquired and released as transactions are carried out on the `db`.
+type Aliases struct {
+	mtx *sync.Mutex
+	db  *bolt.DB
+}
+
+// LoadAliases fetches a boltDb entity at a given `dbpath`. The db just contains
+// a default bucket called `Aliases` which is where the alias entries are
+// stored.
+func LoadAliases(dbpath string) (*Aliases, error) {
+	err := os.MkdirAll(path.Dir(dbpath), os.ModePerm)
+	if os.IsNotExist(err) {
+		return nil, err
+	}
+
+	db, err := openBolt(dbpath, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, lerr := tx.CreateBucketIfNotExists([]byte(bucketName)); lerr != nil {
+			return lerr
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Aliases{
+		mtx: &sync.Mutex{},
+		db:  db,
+	}, nil
+}
+
+// LoadAliasesReadOnly is like LoadAliases, but only takes a shared lock on the
+// db so that any number of readers can have it open at the same time. Writes
+// fail with ErrReadOnly. A db which does not exist yet is created first.
+func LoadAliasesReadOnly(dbpath string) (*Aliases, error) {
+	if _, err := os.Stat(dbpath); os.IsNotExist(err) {
+		a, err := LoadAliases(dbpath)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := openBolt(dbpath, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Aliases{
+		mtx: &sync.Mutex{},
+		db:  db,
+	}, nil
+}
+
+// openBolt opens the bolt db at `dbpath`, giving up after LockTimeout if some
+// other process holds the lock.
+func openBolt(dbpath string, readOnly bool) (*bolt.DB, error) {
+	db, err := bolt.Open(dbpath, 0660, &bolt.Options{
+		Timeout:  LockTimeout,
+		ReadOnly: readOnly,
+	})
+	if err == bolt.ErrTimeout {
+		return nil, fmt.Errorf("%w: %s", ErrDBLocked, dbpath)
+	}
+	return db, err
+}
+
+// Add updates an alias entry or adds a new alias entry. If the alias already
+// exists it is just overwritten.
+func (a *Aliases) Add(alias, mac, iface string) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	// Create a buffer to store the encoded MAC, interface pair.
+	buf, err := EncodeFromMacIface(mac, iface)
+	if err != nil {
+		return err
+	}
+
+	// We don't have to worry about the key existing, as we will update it
+	// provided it exists.
+	return a.writeTx(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		return bucket.Put([]byte(alias), buf.Bytes())
+	})
+}
+
+// Del removes an alias from the store based on the alias string.
+func (a *Aliases) Del(alias string) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.writeTx(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		return bucket.Delete([]byte(alias))
+	})
+}
+
+// Get retrieves the primary MacIface of an alias.
+func (a *Aliases) Get(alias string) (MacIface, error) {
+	entries, err := a.GetAll(alias)
+	if err != nil {
+		return MacIface{}, err
+	}
+	return entries[0], nil
+}
+
+// GetAll retrieves all of the MacIface pairs of an alias.
+func (a *Aliases) GetAll(alias string) ([]MacIface, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	var entries []MacIface
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+
+		bucket := tx.Bucket([]byte(bucketName))
+		value := bucket.Get([]byte(alias))
+		if value == nil {
+			return errAliasNotFound(alias)
+		}
+
+		entries, err = DecodeToMacIfaces(bytes.NewBuffer(value))
+		return err
+	})
+	return entries, err
+}
+
+// AddMac adds a MAC/interface pair to an existing alias.
+func (a *Aliases) AddMac(alias, mac, iface string) error {
+	return a.update(alias, func(entries []MacIface) ([]MacIface, error) {
+		return addMac(entries, mac, iface), nil
+	})
+}
+
+// RemoveMac removes a MAC from an alias.
+func (a *Aliases) RemoveMac(alias, mac string) error {
+	return a.update(alias, func(entries []MacIface) ([]MacIface, error) {
+		return removeMac(alias, entries, mac)
+	})
+}
+
+// SetHost sets the host of a pair of an alias.
+func (a *Aliases) SetHost(alias, mac, host string) error {
+	return a.update(alias, func(entries []MacIface) ([]MacIface, error) {
+		return setHost(alias, entries, mac, host)
+	})
+}
+
+// update replaces the pairs of an existing alias with the result of `fn`, all
+// within a single transaction. Entries in the old single pair format are
+// rewritten in the current one.
+func (a *Aliases) update(alias string, fn func([]MacIface) ([]MacIface, error)) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.writeTx(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		value := bucket.Get([]byte(alias))
+		if value == nil {
+			return errAliasNotFound(alias)
+		}
+
+		entries, err := DecodeToMacIfaces(bytes.NewBuffer(value))
+		if err != nil {
+			return err
+		}
+		if entries, err = fn(entries); err != nil {
+			return err
+		}
+
+		buf, err := EncodeFromMacIfaces(entries)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(alias), buf.Bytes())
+	})
+}
+
+// Rename moves the entry for `from` to `to` in a single transaction, so the
+// alias is never missing (or duplicated) if we are interrupted part way.
+func (a *Aliases) Rename(from, to string) error {
+	return a.move(from, to, false)
+}
+
+// Copy duplicates the entry for `from` as `to`.
+func (a *Aliases) Copy(from, to string) error {
+	return a.move(from, to, true)
+}
+
+// move implements Rename and Copy. The source entry is only deleted if `keep`
+// is false.
+func (a *Aliases) move(from, to string, keep bool) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.writeTx(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		value := bucket.Get([]byte(from))
+		if value == nil {
+			return errAliasNotFound(from)
+		}
+		if bucket.Get([]byte(to)) != nil {
+			return errAliasExists(to)
+		}
+
+		// The value is only valid until the bucket is modified, so take a
+		// copy before writing it back.
+		if err := bucket.Put([]byte(to), append([]byte(nil), value...)); err != nil {
+			return err
+		}
+		if keep {
+			return nil
+		}
+		return bucket.Delete([]byte(from))
+	})
+}
+
+// Edit calls `fn` with the primary entry for `alias` and writes back the
+// result, all within a single transaction.
+func (a *Aliases) Edit(alias string, fn func(mi *MacIface) error) error {
+	return a.update(alias, func(entries []MacIface) ([]MacIface, error) {
+		entry := entries[0]
+		if err := fn(&entry); err != nil {
+			return nil, err
+		}
+		entries[0] = entry
+		return entries, nil
+	})
+}
+
+// List returns a map containing the primary MacIface of every alias.
+func (a *Aliases) List() (map[string]MacIface, error) {
+	aliasMap := make(map[string]MacIface, 1)
+	err := a.ForEach(func(alias string, mi MacIface) error {
+		aliasMap[alias] = mi
+		return nil
+	})
+	return aliasMap, err
+}
+
+// ForEach invokes `fn` with the primary pair of every alias in the store in
+// key order. The store is locked for the duration of the walk, so `fn` must
+// not call back into it.
+func (a *Aliases) ForEach(fn func(alias string, mi MacIface) error) error {
+	return a.ForEachAll(primary(fn))
+}
+
+// ForEachAll invokes `fn` with all of the pairs of every alias in the store in
+// key order. The store is locked for the duration of the walk, so `fn` must
+// not call back into it.
+func (a *Aliases) ForEachAll(fn func(alias string, mis []MacIface) error) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		return bucket.ForEach(func(k, v []byte) error {
+			entries, err := DecodeToMacIfaces(bytes.NewBuffer(v))
+			if err != nil {
+				return err
+			}
+			return fn(string(k), entries)
+		})
+	})
+}
+
+// writeTx runs `fn` in a read-write transaction, reporting ErrReadOnly for a
+// store opened with LoadAliasesReadOnly.
+func (a *Aliases) writeTx(fn func(tx *bolt.Tx) error) error {
+	err := a.db.Update(fn)
+	if err == bolt.ErrDatabaseReadOnly {
+		return fmt.Errorf("%w: %s", ErrReadOnly, a.db.Path())
+	}
+	return err
+}
+
+// Close closes the alias store.
+func (a *Aliases) Close() error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.db.Close()
+}
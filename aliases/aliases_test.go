@@ -1,14 +1,18 @@
-package main
+package aliases
 
 ////////////////////////////////////////////////////////////////////////////////
 
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -25,8 +29,8 @@ var reStripFnPreamble = regexp.MustCompile(`^.*\.(.*)$`)
 // Validate the DecodeToMacIface function.
 func TestDecodeToMacIface(t *testing.T) {
 	var TestCases = []MacIface{
-		{"00:00:00:00:00:00", ""},
-		{"00:00:00:00:00:AA", "eth1"},
+		{Mac: "00:00:00:00:00:00", Iface: ""},
+		{Mac: "00:00:00:00:00:AA", Iface: "eth1"},
 	}
 
 	for _, entry := range TestCases {
@@ -45,8 +49,8 @@ func TestDecodeToMacIface(t *testing.T) {
 // Validate the EncodeFromMacIface function.
 func TestEncodeFromMacIface(t *testing.T) {
 	var TestCases = []MacIface{
-		{"00:00:00:00:00:00", "eth0"},
-		{"00:00:00:00:00:AA", ""},
+		{Mac: "00:00:00:00:00:00", Iface: "eth0"},
+		{Mac: "00:00:00:00:00:AA", Iface: ""},
 	}
 
 	for _, entry := range TestCases {
@@ -61,6 +65,30 @@ func TestEncodeFromMacIface(t *testing.T) {
 	}
 }
 
+// Validate that entries written in the single pair format are still read, and
+// that a single pair is still written in that format.
+func TestDecodeToMacIfaces(t *testing.T) {
+	single, err := EncodeFromMacIface("00:00:00:00:00:01", "eth0")
+	assert.Nil(t, err)
+	entries, err := DecodeToMacIfaces(bytes.NewBuffer(single.Bytes()))
+	assert.Nil(t, err)
+	assert.Equal(t, []MacIface{{Mac: "00:00:00:00:00:01", Iface: "eth0"}}, entries)
+
+	buf, err := EncodeFromMacIfaces(entries)
+	assert.Nil(t, err)
+	assert.Equal(t, single.Bytes(), buf.Bytes())
+
+	multi := []MacIface{{Mac: "00:00:00:00:00:01", Iface: "eth0"}, {Mac: "00:00:00:00:00:02", Iface: ""}}
+	buf, err = EncodeFromMacIfaces(multi)
+	assert.Nil(t, err)
+	entries, err = DecodeToMacIfaces(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, multi, entries)
+
+	_, err = DecodeToMacIfaces(bytes.NewBufferString("garbage"))
+	assert.NotNil(t, err)
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 
 type AliasDBTests struct {
@@ -203,6 +231,53 @@ func (suite *AliasDBTests) TestGetAlias() {
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// Validates opening the same db from two handles. A writer holds the db
+// exclusively, while any number of readers can share it.
+func TestLockedDB(t *testing.T) {
+	defer func(timeout time.Duration) { LockTimeout = timeout }(LockTimeout)
+	LockTimeout = 100 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "wol-bolt")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	dbpath := filepath.Join(dir, "bolt.db")
+
+	// A db which does not exist yet can be opened read-only.
+	reader, err := LoadAliasesReadOnly(dbpath)
+	assert.Nil(t, err)
+	assert.Nil(t, reader.Close())
+
+	writer, err := LoadAliases(dbpath)
+	assert.Nil(t, err)
+	assert.Nil(t, writer.Add("one", "00:00:00:00:00:01", "eth0"))
+
+	_, err = LoadAliases(dbpath)
+	assert.True(t, errors.Is(err, ErrDBLocked))
+	_, err = LoadAliasesReadOnly(dbpath)
+	assert.True(t, errors.Is(err, ErrDBLocked))
+	assert.Nil(t, writer.Close())
+
+	first, err := LoadAliasesReadOnly(dbpath)
+	assert.Nil(t, err)
+	defer first.Close()
+	second, err := LoadAliasesReadOnly(dbpath)
+	assert.Nil(t, err)
+	defer second.Close()
+
+	for _, store := range []*Aliases{first, second} {
+		mi, err := store.Get("one")
+		assert.Nil(t, err)
+		assert.Equal(t, MacIface{Mac: "00:00:00:00:00:01", Iface: "eth0"}, mi)
+	}
+
+	err = first.Add("two", "00:00:00:00:00:02", "")
+	assert.True(t, errors.Is(err, ErrReadOnly))
+	_, err = LoadAliases(dbpath)
+	assert.True(t, errors.Is(err, ErrDBLocked))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
 // Group up all the test suites we wish to run and dispatch them here.
 func TestRunAllSuites(t *testing.T) {
 	suite.Run(t, new(AliasDBTests))
@@ -0,0 +1,20 @@
+// Package aliases implements the alias database used by the wol command, which
+// maps a name to the MAC address (and optional outbound interface) of a
+// machine to wake.
+//
+// The same database can be shared with other programs by opening it through
+// this package:
+//
+//	store, err := aliases.OpenStore(aliases.StoreBolt, "/home/me/.config/go-wol/bolt.db")
+//	if err != nil {
+//		return err
+//	}
+//	defer store.Close()
+//
+//	mi, err := store.Get("skynet")
+//
+// Three backends are available: a BoltDB file (the default used by the CLI),
+// a plain JSON file and an in-memory map. All of them implement Store and
+// report missing or duplicate aliases with an *AliasError wrapping
+// ErrAliasNotFound or ErrAliasExists, which can be matched with errors.Is.
+package aliases
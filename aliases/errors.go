@@ -0,0 +1,69 @@
+package aliases
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"fmt"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+var (
+	// ErrAliasNotFound is returned when an alias does not exist in the store.
+	ErrAliasNotFound = errors.New("alias not found")
+
+	// ErrAliasExists is returned when a rename or copy would overwrite an
+	// existing alias.
+	ErrAliasExists = errors.New("alias already exists")
+
+	// ErrMACNotFound is returned when removing a MAC address which is not
+	// part of an alias.
+	ErrMACNotFound = errors.New("mac address not found")
+
+	// ErrLastMAC is returned when removing the only MAC address of an alias,
+	// the alias itself should be removed instead.
+	ErrLastMAC = errors.New("cannot remove the last mac address of an alias")
+
+	// ErrDBLocked is returned when the alias db is held open by another
+	// process.
+	ErrDBLocked = errors.New("database locked by another process")
+
+	// ErrReadOnly is returned when writing to a store opened read-only.
+	ErrReadOnly = errors.New("database opened read-only")
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// AliasError describes a failed operation on a specific alias. `Err` is either
+// ErrAliasNotFound or ErrAliasExists.
+type AliasError struct {
+	Alias string
+	Err   error
+}
+
+func (e *AliasError) Error() string {
+	if e.Err == ErrAliasExists {
+		return fmt.Sprintf("alias (%s) already exists in db", e.Alias)
+	}
+	return fmt.Sprintf("alias (%s) not found in db", e.Alias)
+}
+
+// Unwrap allows errors.Is to match the underlying sentinel error.
+func (e *AliasError) Unwrap() error {
+	return e.Err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// errAliasNotFound returns the error reported by all stores when a lookup is
+// made for an alias which does not exist.
+func errAliasNotFound(alias string) error {
+	return &AliasError{Alias: alias, Err: ErrAliasNotFound}
+}
+
+// errAliasExists returns the error reported by all stores when an alias is
+// about to be overwritten by a rename or copy.
+func errAliasExists(alias string) error {
+	return &AliasError{Alias: alias, Err: ErrAliasExists}
+}
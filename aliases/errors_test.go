@@ -0,0 +1,29 @@
+package aliases
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestAliasErrors(t *testing.T) {
+	store := NewMemStore()
+	assert.Nil(t, store.Add("foo", "00:11:22:33:44:55", ""))
+
+	_, err := store.Get("bar")
+	assert.True(t, errors.Is(err, ErrAliasNotFound))
+	assert.Equal(t, "alias (bar) not found in db", err.Error())
+
+	var aliasErr *AliasError
+	assert.True(t, errors.As(err, &aliasErr))
+	assert.Equal(t, "bar", aliasErr.Alias)
+
+	err = store.Copy("foo", "foo")
+	assert.True(t, errors.Is(err, ErrAliasExists))
+	assert.Equal(t, "alias (foo) already exists in db", err.Error())
+}
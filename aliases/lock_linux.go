@@ -0,0 +1,43 @@
+package aliases
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// lockFile takes an exclusive flock on the file at `path`, creating it if
+// needed, and returns a function releasing it. Like opening a bolt db, it
+// gives up after LockTimeout if some other process holds the lock.
+func lockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0660)
+	if err != nil {
+		return nil, err
+	}
+
+	fd := int(f.Fd())
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		err := unix.Flock(fd, unix.LOCK_EX|unix.LOCK_NB)
+		switch {
+		case err == nil:
+			return func() {
+				unix.Flock(fd, unix.LOCK_UN)
+				f.Close()
+			}, nil
+		case err != unix.EWOULDBLOCK:
+			f.Close()
+			return nil, err
+		case time.Now().After(deadline):
+			f.Close()
+			return nil, fmt.Errorf("%w: %s", ErrDBLocked, path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,49 @@
+package aliases
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Validates that stores of the same file in different processes do not lose
+// each other's changes, and leave no temporary files behind.
+func TestJSONStoreConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wol-json")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "aliases.json")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		store, err := LoadJSONStore(path)
+		assert.Nil(t, err)
+		wg.Add(1)
+		go func(i int, store *JSONStore) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				assert.Nil(t, store.Add(fmt.Sprintf("alias-%d-%d", i, j), "00:00:00:00:00:01", ""))
+			}
+		}(i, store)
+	}
+	wg.Wait()
+
+	store, err := LoadJSONStore(path)
+	assert.Nil(t, err)
+	list, err := store.List()
+	assert.Nil(t, err)
+	assert.Len(t, list, 40)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	assert.Nil(t, err)
+	assert.Len(t, files, 0)
+}
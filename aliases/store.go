@@ -0,0 +1,312 @@
+package aliases
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sabhiram/go-wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Kinds of store which can be passed to OpenStore.
+const (
+	StoreBolt   = "bolt"
+	StoreJSON   = "json"
+	StoreMemory = "memory"
+)
+
+// defaultDBNames maps each store backend to its conventional file name.
+var defaultDBNames = map[string]string{
+	StoreBolt:   "bolt.db",
+	StoreJSON:   "aliases.json",
+	StoreMemory: "",
+}
+
+// DefaultDBName returns the file name the wol command uses for a store of the
+// given `kind`, or "" for the in-memory store and unknown kinds.
+func DefaultDBName(kind string) string {
+	return defaultDBNames[kind]
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Store is implemented by anything capable of persisting alias entries. All of
+// the methods are safe for concurrent use.
+//
+// An alias holds one or more MAC/interface pairs, for machines with several
+// NICs. The first pair is the primary one, which is what the single pair
+// methods (Get, Edit, List and ForEach) operate on.
+type Store interface {
+	// Add sets the alias to the single given MAC/interface pair, replacing
+	// whatever was stored for it before.
+	Add(alias, mac, iface string) error
+
+	// AddMac adds another MAC/interface pair to an existing alias. If the MAC
+	// is already part of the alias only its interface is updated.
+	AddMac(alias, mac, iface string) error
+
+	// RemoveMac removes a MAC from an alias. It fails if the MAC is not part
+	// of the alias, or if it is the only one left.
+	RemoveMac(alias, mac string) error
+
+	// SetHost sets the IP address or host name of the pair of `alias` with
+	// `mac`, or of the primary pair if `mac` is empty. An empty `host`
+	// clears it.
+	SetHost(alias, mac, host string) error
+
+	// Del removes an alias from the store.
+	Del(alias string) error
+
+	// Get retrieves the primary MacIface for a given alias.
+	Get(alias string) (MacIface, error)
+
+	// GetAll retrieves all of the MacIface pairs for a given alias, primary
+	// first.
+	GetAll(alias string) ([]MacIface, error)
+
+	// Rename moves the entry for `from` to `to`. It fails if `from` does not
+	// exist or `to` already does.
+	Rename(from, to string) error
+
+	// Copy duplicates the entry for `from` as `to`. It fails if `from` does
+	// not exist or `to` already does.
+	Copy(from, to string) error
+
+	// Edit calls `fn` with the current primary entry for `alias` and stores
+	// whatever `fn` leaves behind, unless it returns an error.
+	Edit(alias string, fn func(mi *MacIface) error) error
+
+	// List returns a map containing the primary MacIface of every alias.
+	List() (map[string]MacIface, error)
+
+	// ForEach invokes `fn` with the primary MacIface of each alias in the
+	// store in sorted order. Any error returned by `fn` stops the iteration
+	// and is returned.
+	ForEach(fn func(alias string, mi MacIface) error) error
+
+	// ForEachAll is like ForEach, but passes all of the pairs of each alias.
+	ForEachAll(fn func(alias string, mis []MacIface) error) error
+
+	// Close releases any resources held by the store.
+	Close() error
+}
+
+// Make sure all of the backends stay in sync with the interface.
+var (
+	_ Store = (*Aliases)(nil)
+	_ Store = (*JSONStore)(nil)
+	_ Store = (*MemStore)(nil)
+	_ Store = (*Layered)(nil)
+)
+
+// OpenStore returns a Store of the requested `kind` backed by the file at
+// `dbpath` (which is ignored for the in-memory store).
+func OpenStore(kind, dbpath string) (Store, error) {
+	switch kind {
+	case StoreBolt:
+		return LoadAliases(dbpath)
+	case StoreJSON:
+		return LoadJSONStore(dbpath)
+	case StoreMemory:
+		return NewMemStore(), nil
+	}
+	return nil, fmt.Errorf("unknown store type %q (expected one of: %s, %s, %s)",
+		kind, StoreBolt, StoreJSON, StoreMemory)
+}
+
+// OpenStoreReadOnly is like OpenStore, but opens a bolt store read-only so it
+// can be shared with other readers. The other kinds do not lock their file and
+// are opened as usual.
+func OpenStoreReadOnly(kind, dbpath string) (Store, error) {
+	if kind == StoreBolt {
+		return LoadAliasesReadOnly(dbpath)
+	}
+	return OpenStore(kind, dbpath)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// sameMAC reports whether `a` and `b` are the same hardware address, however
+// they happen to be formatted.
+func sameMAC(a, b string) bool {
+	ma, erra := wol.ParseMAC(a)
+	mb, errb := wol.ParseMAC(b)
+	if erra != nil || errb != nil {
+		return strings.EqualFold(a, b)
+	}
+	return ma == mb
+}
+
+// addMac returns `mis` with the pair `mac`, `iface` added, or with the
+// interface of `mac` updated if it is already present.
+func addMac(mis []MacIface, mac, iface string) []MacIface {
+	out := append([]MacIface(nil), mis...)
+	for i := range out {
+		if sameMAC(out[i].Mac, mac) {
+			out[i].Iface = iface
+			return out
+		}
+	}
+	return append(out, MacIface{Mac: mac, Iface: iface})
+}
+
+// removeMac returns the pairs of `alias` in `mis` without `mac`.
+func removeMac(alias string, mis []MacIface, mac string) ([]MacIface, error) {
+	for i := range mis {
+		if !sameMAC(mis[i].Mac, mac) {
+			continue
+		}
+		if len(mis) == 1 {
+			return nil, fmt.Errorf("%w: %s of alias (%s)", ErrLastMAC, mac, alias)
+		}
+		out := append([]MacIface(nil), mis[:i]...)
+		return append(out, mis[i+1:]...), nil
+	}
+	return nil, fmt.Errorf("%w: %s is not a MAC address of alias (%s)", ErrMACNotFound, mac, alias)
+}
+
+// setHost returns the pairs of `alias` in `mis` with the host of `mac`, or of
+// the primary pair, set to `host`.
+func setHost(alias string, mis []MacIface, mac, host string) ([]MacIface, error) {
+	for i := range mis {
+		if len(mac) == 0 || sameMAC(mis[i].Mac, mac) {
+			out := append([]MacIface(nil), mis...)
+			out[i].Host = host
+			return out, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s is not a MAC address of alias (%s)", ErrMACNotFound, mac, alias)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// macMap holds the aliases of the map backed stores. None of the methods lock,
+// that is left to the stores.
+type macMap map[string][]MacIface
+
+// get implements GetAll, returning a copy of the pairs of `alias`.
+func (mp macMap) get(alias string) ([]MacIface, error) {
+	mis, ok := mp[alias]
+	if !ok {
+		return nil, errAliasNotFound(alias)
+	}
+	return append([]MacIface(nil), mis...), nil
+}
+
+// addMac implements AddMac.
+func (mp macMap) addMac(alias, mac, iface string) error {
+	mis, ok := mp[alias]
+	if !ok {
+		return errAliasNotFound(alias)
+	}
+	mp[alias] = addMac(mis, mac, iface)
+	return nil
+}
+
+// removeMac implements RemoveMac.
+func (mp macMap) removeMac(alias, mac string) error {
+	mis, ok := mp[alias]
+	if !ok {
+		return errAliasNotFound(alias)
+	}
+	mis, err := removeMac(alias, mis, mac)
+	if err != nil {
+		return err
+	}
+	mp[alias] = mis
+	return nil
+}
+
+// setHost implements SetHost.
+func (mp macMap) setHost(alias, mac, host string) error {
+	mis, ok := mp[alias]
+	if !ok {
+		return errAliasNotFound(alias)
+	}
+	mis, err := setHost(alias, mis, mac, host)
+	if err != nil {
+		return err
+	}
+	mp[alias] = mis
+	return nil
+}
+
+// move implements Rename and Copy. The entry is removed from `from` only if
+// `keep` is false.
+func (mp macMap) move(from, to string, keep bool) error {
+	mis, ok := mp[from]
+	if !ok {
+		return errAliasNotFound(from)
+	}
+	if _, ok := mp[to]; ok {
+		return errAliasExists(to)
+	}
+	mp[to] = append([]MacIface(nil), mis...)
+	if !keep {
+		delete(mp, from)
+	}
+	return nil
+}
+
+// edit implements Edit.
+func (mp macMap) edit(alias string, fn func(*MacIface) error) error {
+	mis, ok := mp[alias]
+	if !ok {
+		return errAliasNotFound(alias)
+	}
+	entry := mis[0]
+	if err := fn(&entry); err != nil {
+		return err
+	}
+	mis = append([]MacIface(nil), mis...)
+	mis[0] = entry
+	mp[alias] = mis
+	return nil
+}
+
+// copy returns a deep copy of the map, so it can be walked without holding
+// the store's lock.
+func (mp macMap) copy() macMap {
+	out := make(macMap, len(mp))
+	for k, v := range mp {
+		out[k] = append([]MacIface(nil), v...)
+	}
+	return out
+}
+
+// primaries implements List.
+func (mp macMap) primaries() map[string]MacIface {
+	out := make(map[string]MacIface, len(mp))
+	for k, v := range mp {
+		out[k] = v[0]
+	}
+	return out
+}
+
+// forEachSorted walks the aliases in sorted key order, used by the map backed
+// stores to provide the same iteration order as bolt.
+func (mp macMap) forEachSorted(fn func(string, []MacIface) error) error {
+	keys := make([]string, 0, len(mp))
+	for k := range mp {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := fn(k, mp[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// primary adapts a ForEach callback to ForEachAll.
+func primary(fn func(string, MacIface) error) func(string, []MacIface) error {
+	return func(alias string, mis []MacIface) error {
+		return fn(alias, mis[0])
+	}
+}
@@ -0,0 +1,289 @@
+package aliases
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// JSONStore is a Store which persists aliases as a plain JSON object in a
+// single file. The whole file is rewritten on every change which is fine for
+// the handful of aliases a typical user has.
+type JSONStore struct {
+	mtx     *sync.Mutex
+	path    string
+	aliases macMap
+}
+
+// jsonEntry is how the pairs of an alias are written to the JSON file. An
+// alias with a single pair is written as an object, exactly like files written
+// before aliases could hold several pairs, and as an array otherwise.
+type jsonEntry []MacIface
+
+// MarshalJSON implements json.Marshaler.
+func (e jsonEntry) MarshalJSON() ([]byte, error) {
+	if len(e) == 1 {
+		return json.Marshal(e[0])
+	}
+	return json.Marshal([]MacIface(e))
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting both forms.
+func (e *jsonEntry) UnmarshalJSON(bs []byte) error {
+	if !bytes.HasPrefix(bytes.TrimSpace(bs), []byte("[")) {
+		var mi MacIface
+		if err := json.Unmarshal(bs, &mi); err != nil {
+			return err
+		}
+		*e = jsonEntry{mi}
+		return nil
+	}
+
+	var mis []MacIface
+	if err := json.Unmarshal(bs, &mis); err != nil {
+		return err
+	}
+	if len(mis) == 0 {
+		return fmt.Errorf("alias has no mac addresses")
+	}
+	*e = mis
+	return nil
+}
+
+// LoadJSONStore reads the aliases stored in the JSON file at `dbpath`. The
+// file is created on the first write if it does not already exist.
+func LoadJSONStore(dbpath string) (*JSONStore, error) {
+	err := os.MkdirAll(path.Dir(dbpath), os.ModePerm)
+	if os.IsNotExist(err) {
+		return nil, err
+	}
+
+	aliases, err := loadJSON(dbpath)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONStore{
+		mtx:     &sync.Mutex{},
+		path:    dbpath,
+		aliases: aliases,
+	}, nil
+}
+
+// loadJSON reads the aliases in the JSON file at `dbpath`, there are none if
+// it does not exist.
+func loadJSON(dbpath string) (macMap, error) {
+	entries := map[string]jsonEntry{}
+	bs, err := ioutil.ReadFile(dbpath)
+	switch {
+	case os.IsNotExist(err):
+		// Nothing stored yet.
+	case err != nil:
+		return nil, err
+	case len(bs) > 0:
+		if err := json.Unmarshal(bs, &entries); err != nil {
+			return nil, err
+		}
+	}
+
+	aliases := make(macMap, len(entries))
+	for k, v := range entries {
+		aliases[k] = v
+	}
+	return aliases, nil
+}
+
+// update applies `fn` to the aliases and only keeps the result once it has
+// been saved, so that the aliases in memory never disagree with the file when
+// a write fails. The file is locked and read again first, so that the changes
+// of other processes are not lost. The caller must hold `mtx`.
+func (j *JSONStore) update(fn func(mp macMap) error) error {
+	unlock, err := lockFile(j.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	mp, err := loadJSON(j.path)
+	if err != nil {
+		return err
+	}
+	if err := fn(mp); err != nil {
+		return err
+	}
+	if err := j.save(mp); err != nil {
+		return err
+	}
+	j.aliases = mp
+	return nil
+}
+
+// save writes the aliases `mp` to disk. The contents are written to a
+// temporary file next to it first and then renamed over the original so that
+// a crash part way through never leaves a truncated file behind.
+func (j *JSONStore) save(mp macMap) error {
+	entries := make(map[string]jsonEntry, len(mp))
+	for k, v := range mp {
+		entries[k] = v
+	}
+	bs, err := json.MarshalIndent(entries, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(j.path), filepath.Base(j.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(bs, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0660)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), j.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Add sets an alias to a single MAC/interface pair.
+func (j *JSONStore) Add(alias, mac, iface string) error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	return j.update(func(mp macMap) error {
+		mp[alias] = []MacIface{{Mac: mac, Iface: iface}}
+		return nil
+	})
+}
+
+// AddMac adds a MAC/interface pair to an existing alias.
+func (j *JSONStore) AddMac(alias, mac, iface string) error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	return j.update(func(mp macMap) error {
+		return mp.addMac(alias, mac, iface)
+	})
+}
+
+// RemoveMac removes a MAC from an alias.
+func (j *JSONStore) RemoveMac(alias, mac string) error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	return j.update(func(mp macMap) error {
+		return mp.removeMac(alias, mac)
+	})
+}
+
+// SetHost sets the host of a pair of an alias.
+func (j *JSONStore) SetHost(alias, mac, host string) error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	return j.update(func(mp macMap) error {
+		return mp.setHost(alias, mac, host)
+	})
+}
+
+// Del removes an alias from the store based on the alias string.
+func (j *JSONStore) Del(alias string) error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	if _, ok := j.aliases[alias]; !ok {
+		return nil
+	}
+	return j.update(func(mp macMap) error {
+		delete(mp, alias)
+		return nil
+	})
+}
+
+// Get retrieves the primary MacIface of an alias.
+func (j *JSONStore) Get(alias string) (MacIface, error) {
+	mis, err := j.GetAll(alias)
+	if err != nil {
+		return MacIface{}, err
+	}
+	return mis[0], nil
+}
+
+// GetAll retrieves all of the MacIface pairs of an alias.
+func (j *JSONStore) GetAll(alias string) ([]MacIface, error) {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	return j.aliases.get(alias)
+}
+
+// Rename moves the entry for `from` to `to`.
+func (j *JSONStore) Rename(from, to string) error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	return j.update(func(mp macMap) error {
+		return mp.move(from, to, false)
+	})
+}
+
+// Copy duplicates the entry for `from` as `to`.
+func (j *JSONStore) Copy(from, to string) error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	return j.update(func(mp macMap) error {
+		return mp.move(from, to, true)
+	})
+}
+
+// Edit updates the primary entry for `alias` in place using `fn`.
+func (j *JSONStore) Edit(alias string, fn func(mi *MacIface) error) error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	return j.update(func(mp macMap) error {
+		return mp.edit(alias, fn)
+	})
+}
+
+// List returns a map containing the primary MacIface of every alias.
+func (j *JSONStore) List() (map[string]MacIface, error) {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	return j.aliases.primaries(), nil
+}
+
+// ForEach invokes `fn` for every alias in sorted order.
+func (j *JSONStore) ForEach(fn func(alias string, mi MacIface) error) error {
+	return j.ForEachAll(primary(fn))
+}
+
+// ForEachAll invokes `fn` with all pairs of every alias in sorted order.
+func (j *JSONStore) ForEachAll(fn func(alias string, mis []MacIface) error) error {
+	j.mtx.Lock()
+	mp := j.aliases.copy()
+	j.mtx.Unlock()
+
+	return mp.forEachSorted(fn)
+}
+
+// Close is a no-op for the JSON store since every change is written through.
+func (j *JSONStore) Close() error {
+	return nil
+}
@@ -0,0 +1,258 @@
+package aliases
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"fmt"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Names of the layers reported by Layered.Layer.
+const (
+	LayerUser   = "user"
+	LayerSystem = "system"
+)
+
+// Layered is a Store made up of a writable per-user store layered on top of a
+// read-only system-wide one. Aliases in the user store override the system
+// aliases of the same name, and all writes go to the user store. Changing the
+// MAC addresses of a system alias stores the changed copy as a user override.
+type Layered struct {
+	user   Store
+	system Store
+}
+
+// NewLayered returns a Store which reads from `user` and then `system`, and
+// only ever writes to `user`. Closing it closes both.
+func NewLayered(user, system Store) *Layered {
+	return &Layered{user: user, system: system}
+}
+
+// Layer reports which layer the entry for `alias` comes from.
+func (l *Layered) Layer(alias string) (string, error) {
+	if _, err := l.user.GetAll(alias); !errors.Is(err, ErrAliasNotFound) {
+		return LayerUser, err
+	}
+	if _, err := l.system.GetAll(alias); err != nil {
+		return "", err
+	}
+	return LayerSystem, nil
+}
+
+// Add sets the alias in the user store.
+func (l *Layered) Add(alias, mac, iface string) error {
+	return l.user.Add(alias, mac, iface)
+}
+
+// AddMac adds a MAC/interface pair to an alias from either layer.
+func (l *Layered) AddMac(alias, mac, iface string) error {
+	if l.inUser(alias) {
+		return l.user.AddMac(alias, mac, iface)
+	}
+	return l.override(alias, func(mis []MacIface) ([]MacIface, error) {
+		return addMac(mis, mac, iface), nil
+	})
+}
+
+// RemoveMac removes a MAC from an alias from either layer.
+func (l *Layered) RemoveMac(alias, mac string) error {
+	if l.inUser(alias) {
+		return l.user.RemoveMac(alias, mac)
+	}
+	return l.override(alias, func(mis []MacIface) ([]MacIface, error) {
+		return removeMac(alias, mis, mac)
+	})
+}
+
+// SetHost sets the host of a pair of an alias from either layer.
+func (l *Layered) SetHost(alias, mac, host string) error {
+	if l.inUser(alias) {
+		return l.user.SetHost(alias, mac, host)
+	}
+	return l.override(alias, func(mis []MacIface) ([]MacIface, error) {
+		return setHost(alias, mis, mac, host)
+	})
+}
+
+// Del removes an alias from the user store, which uncovers the system alias of
+// the same name if there is one. System aliases can not be removed.
+func (l *Layered) Del(alias string) error {
+	if !l.inUser(alias) {
+		if _, err := l.system.GetAll(alias); err == nil {
+			return errSystemAlias(alias)
+		}
+	}
+	return l.user.Del(alias)
+}
+
+// Get retrieves the primary MacIface for a given alias.
+func (l *Layered) Get(alias string) (MacIface, error) {
+	mis, err := l.GetAll(alias)
+	if err != nil {
+		return MacIface{}, err
+	}
+	return mis[0], nil
+}
+
+// GetAll retrieves all of the MacIface pairs for a given alias, preferring
+// the user store.
+func (l *Layered) GetAll(alias string) ([]MacIface, error) {
+	mis, err := l.user.GetAll(alias)
+	if errors.Is(err, ErrAliasNotFound) {
+		return l.system.GetAll(alias)
+	}
+	return mis, err
+}
+
+// Rename renames a user alias. It fails if `to` exists in either layer.
+func (l *Layered) Rename(from, to string) error {
+	if err := l.checkFree(to); err != nil {
+		return err
+	}
+	if !l.inUser(from) {
+		if _, err := l.system.GetAll(from); err == nil {
+			return errSystemAlias(from)
+		}
+	}
+	return l.user.Rename(from, to)
+}
+
+// Copy duplicates an alias from either layer into the user store. It fails if
+// `to` exists in either layer.
+func (l *Layered) Copy(from, to string) error {
+	mis, err := l.GetAll(from)
+	if err != nil {
+		return err
+	}
+	if err := l.checkFree(to); err != nil {
+		return err
+	}
+	return putAll(l.user, to, mis)
+}
+
+// Edit updates the primary entry of an alias from either layer.
+func (l *Layered) Edit(alias string, fn func(mi *MacIface) error) error {
+	if l.inUser(alias) {
+		return l.user.Edit(alias, fn)
+	}
+	return l.override(alias, func(mis []MacIface) ([]MacIface, error) {
+		entry := mis[0]
+		if err := fn(&entry); err != nil {
+			return nil, err
+		}
+		mis = append([]MacIface(nil), mis...)
+		mis[0] = entry
+		return mis, nil
+	})
+}
+
+// List returns a map containing the primary MacIface of every alias.
+func (l *Layered) List() (map[string]MacIface, error) {
+	list := map[string]MacIface{}
+	err := l.ForEach(func(alias string, mi MacIface) error {
+		list[alias] = mi
+		return nil
+	})
+	return list, err
+}
+
+// ForEach invokes `fn` with the primary MacIface of each alias in sorted order.
+func (l *Layered) ForEach(fn func(alias string, mi MacIface) error) error {
+	return l.ForEachAll(primary(fn))
+}
+
+// ForEachAll invokes `fn` with all of the pairs of each alias in sorted order.
+// System aliases which are overridden by the user are skipped.
+func (l *Layered) ForEachAll(fn func(alias string, mis []MacIface) error) error {
+	merged, err := l.merged()
+	if err != nil {
+		return err
+	}
+	return merged.forEachSorted(fn)
+}
+
+// Close closes both layers.
+func (l *Layered) Close() error {
+	uerr := l.user.Close()
+	if serr := l.system.Close(); uerr == nil {
+		uerr = serr
+	}
+	return uerr
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// merged reads both layers into a single map, the user entries winning.
+func (l *Layered) merged() (macMap, error) {
+	out := macMap{}
+	for _, store := range []Store{l.system, l.user} {
+		err := store.ForEachAll(func(alias string, mis []MacIface) error {
+			out[alias] = append([]MacIface(nil), mis...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// inUser reports whether `alias` is in the user store.
+func (l *Layered) inUser(alias string) bool {
+	_, err := l.user.GetAll(alias)
+	return !errors.Is(err, ErrAliasNotFound)
+}
+
+// override applies `fn` to the pairs of the system alias `alias` and stores the
+// result in the user store.
+func (l *Layered) override(alias string, fn func([]MacIface) ([]MacIface, error)) error {
+	mis, err := l.system.GetAll(alias)
+	if err != nil {
+		return err
+	}
+	mis, err = fn(mis)
+	if err != nil {
+		return err
+	}
+	return putAll(l.user, alias, mis)
+}
+
+// checkFree fails if `alias` exists in either layer.
+func (l *Layered) checkFree(alias string) error {
+	if _, err := l.GetAll(alias); err == nil {
+		return errAliasExists(alias)
+	} else if !errors.Is(err, ErrAliasNotFound) {
+		return err
+	}
+	return nil
+}
+
+// putAll sets `alias` in `store` to exactly the pairs in `mis`.
+func putAll(store Store, alias string, mis []MacIface) error {
+	if err := store.Add(alias, mis[0].Mac, mis[0].Iface); err != nil {
+		return err
+	}
+	for _, mi := range mis[1:] {
+		if err := store.AddMac(alias, mi.Mac, mi.Iface); err != nil {
+			return err
+		}
+	}
+	for _, mi := range mis {
+		if len(mi.Host) == 0 {
+			continue
+		}
+		if err := store.SetHost(alias, mi.Mac, mi.Host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errSystemAlias is returned when trying to remove or rename an alias which
+// only exists in the read-only system store.
+func errSystemAlias(alias string) error {
+	return fmt.Errorf("%w: alias (%s) comes from the system-wide db, it can only be overridden",
+		ErrReadOnly, alias)
+}
@@ -0,0 +1,134 @@
+package aliases
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"sync"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// MemStore is a Store which only keeps aliases in memory. It is useful for
+// tests and for embedding where nothing should be persisted to disk.
+type MemStore struct {
+	mtx     *sync.Mutex
+	aliases macMap
+}
+
+// NewMemStore returns an empty in-memory alias store.
+func NewMemStore() *MemStore {
+	return &MemStore{
+		mtx:     &sync.Mutex{},
+		aliases: macMap{},
+	}
+}
+
+// Add sets an alias to a single MAC/interface pair.
+func (m *MemStore) Add(alias, mac, iface string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.aliases[alias] = []MacIface{{Mac: mac, Iface: iface}}
+	return nil
+}
+
+// AddMac adds a MAC/interface pair to an existing alias.
+func (m *MemStore) AddMac(alias, mac, iface string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.aliases.addMac(alias, mac, iface)
+}
+
+// RemoveMac removes a MAC from an alias.
+func (m *MemStore) RemoveMac(alias, mac string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.aliases.removeMac(alias, mac)
+}
+
+// SetHost sets the host of a pair of an alias.
+func (m *MemStore) SetHost(alias, mac, host string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.aliases.setHost(alias, mac, host)
+}
+
+// Del removes an alias from the store based on the alias string.
+func (m *MemStore) Del(alias string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	delete(m.aliases, alias)
+	return nil
+}
+
+// Get retrieves the primary MacIface of an alias.
+func (m *MemStore) Get(alias string) (MacIface, error) {
+	mis, err := m.GetAll(alias)
+	if err != nil {
+		return MacIface{}, err
+	}
+	return mis[0], nil
+}
+
+// GetAll retrieves all of the MacIface pairs of an alias.
+func (m *MemStore) GetAll(alias string) ([]MacIface, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.aliases.get(alias)
+}
+
+// Rename moves the entry for `from` to `to`.
+func (m *MemStore) Rename(from, to string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.aliases.move(from, to, false)
+}
+
+// Copy duplicates the entry for `from` as `to`.
+func (m *MemStore) Copy(from, to string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.aliases.move(from, to, true)
+}
+
+// Edit updates the primary entry for `alias` in place using `fn`.
+func (m *MemStore) Edit(alias string, fn func(mi *MacIface) error) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.aliases.edit(alias, fn)
+}
+
+// List returns a map containing the primary MacIface of every alias.
+func (m *MemStore) List() (map[string]MacIface, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.aliases.primaries(), nil
+}
+
+// ForEach invokes `fn` for every alias in sorted order.
+func (m *MemStore) ForEach(fn func(alias string, mi MacIface) error) error {
+	return m.ForEachAll(primary(fn))
+}
+
+// ForEachAll invokes `fn` with all pairs of every alias in sorted order.
+func (m *MemStore) ForEachAll(fn func(alias string, mis []MacIface) error) error {
+	m.mtx.Lock()
+	mp := m.aliases.copy()
+	m.mtx.Unlock()
+
+	return mp.forEachSorted(fn)
+}
+
+// Close is a no-op for the in-memory store.
+func (m *MemStore) Close() error {
+	return nil
+}
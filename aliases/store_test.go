@@ -0,0 +1,460 @@
+package aliases
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// StoreTests validates the behavior every Store implementation must share.
+// The suite is run once per backend.
+type StoreTests struct {
+	suite.Suite
+	kind  string
+	dir   string
+	store Store
+}
+
+func (suite *StoreTests) SetupTest() {
+	var err error
+	suite.dir, err = ioutil.TempDir("", "wol-store")
+	assert.Nil(suite.T(), err)
+
+	suite.store, err = OpenStore(suite.kind, filepath.Join(suite.dir, "aliases"))
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *StoreTests) TearDownTest() {
+	assert.Nil(suite.T(), suite.store.Close())
+	assert.Nil(suite.T(), os.RemoveAll(suite.dir))
+}
+
+// Validates Add, Get and Del round trip through the store.
+func (suite *StoreTests) TestAddGetDel() {
+	t := suite.T()
+
+	assert.Nil(t, suite.store.Add("one", "00:00:00:00:00:01", "eth0"))
+	assert.Nil(t, suite.store.Add("two", "00:00:00:00:00:02", ""))
+
+	mi, err := suite.store.Get("one")
+	assert.Nil(t, err)
+	assert.Equal(t, MacIface{Mac: "00:00:00:00:00:01", Iface: "eth0"}, mi)
+
+	// Overwrite an existing entry.
+	assert.Nil(t, suite.store.Add("one", "00:00:00:00:00:03", ""))
+	mi, err = suite.store.Get("one")
+	assert.Nil(t, err)
+	assert.Equal(t, MacIface{Mac: "00:00:00:00:00:03", Iface: ""}, mi)
+
+	assert.Nil(t, suite.store.Del("one"))
+	_, err = suite.store.Get("one")
+	assert.NotNil(t, err)
+
+	// Deleting something which does not exist is not an error.
+	assert.Nil(t, suite.store.Del("one"))
+
+	list, err := suite.store.List()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(list))
+}
+
+// Validates that ForEach walks the aliases in sorted order and stops on error.
+func (suite *StoreTests) TestForEach() {
+	t := suite.T()
+
+	for _, alias := range []string{"c", "a", "b"} {
+		assert.Nil(t, suite.store.Add(alias, "00:00:00:00:00:00", ""))
+	}
+
+	var seen []string
+	err := suite.store.ForEach(func(alias string, mi MacIface) error {
+		seen = append(seen, alias)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, seen)
+
+	stop := errors.New("stop")
+	count := 0
+	err = suite.store.ForEach(func(alias string, mi MacIface) error {
+		count++
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, count)
+}
+
+// Validates Rename and Copy, including their failure modes.
+func (suite *StoreTests) TestRenameCopy() {
+	t := suite.T()
+
+	assert.Nil(t, suite.store.Add("one", "00:00:00:00:00:01", "eth0"))
+	assert.Nil(t, suite.store.Add("two", "00:00:00:00:00:02", ""))
+
+	assert.Nil(t, suite.store.Rename("one", "uno"))
+	_, err := suite.store.Get("one")
+	assert.NotNil(t, err)
+	mi, err := suite.store.Get("uno")
+	assert.Nil(t, err)
+	assert.Equal(t, MacIface{Mac: "00:00:00:00:00:01", Iface: "eth0"}, mi)
+
+	assert.Nil(t, suite.store.Copy("uno", "eins"))
+	for _, alias := range []string{"uno", "eins"} {
+		mi, err = suite.store.Get(alias)
+		assert.Nil(t, err)
+		assert.Equal(t, MacIface{Mac: "00:00:00:00:00:01", Iface: "eth0"}, mi)
+	}
+
+	// The target must not exist and the source must.
+	assert.NotNil(t, suite.store.Rename("uno", "two"))
+	assert.NotNil(t, suite.store.Copy("uno", "two"))
+	assert.NotNil(t, suite.store.Rename("missing", "three"))
+	assert.NotNil(t, suite.store.Copy("missing", "three"))
+
+	// Nothing changed by the failed operations.
+	list, err := suite.store.List()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(list))
+	assert.Equal(t, "00:00:00:00:00:02", list["two"].Mac)
+}
+
+// Validates partial updates through Edit.
+func (suite *StoreTests) TestEdit() {
+	t := suite.T()
+
+	assert.Nil(t, suite.store.Add("one", "00:00:00:00:00:01", "eth0"))
+	assert.Nil(t, suite.store.Edit("one", func(mi *MacIface) error {
+		mi.Iface = "eth1"
+		return nil
+	}))
+	mi, err := suite.store.Get("one")
+	assert.Nil(t, err)
+	assert.Equal(t, MacIface{Mac: "00:00:00:00:00:01", Iface: "eth1"}, mi)
+
+	// An error from the callback leaves the entry untouched.
+	assert.NotNil(t, suite.store.Edit("one", func(mi *MacIface) error {
+		mi.Mac = "garbage"
+		return errors.New("nope")
+	}))
+	mi, err = suite.store.Get("one")
+	assert.Nil(t, err)
+	assert.Equal(t, MacIface{Mac: "00:00:00:00:00:01", Iface: "eth1"}, mi)
+
+	assert.NotNil(t, suite.store.Edit("missing", func(mi *MacIface) error {
+		return nil
+	}))
+}
+
+// Validates aliases holding several MAC addresses.
+func (suite *StoreTests) TestMultipleMacs() {
+	t := suite.T()
+
+	assert.Nil(t, suite.store.Add("srv", "00:00:00:00:00:01", "eth0"))
+	assert.Nil(t, suite.store.AddMac("srv", "00:00:00:00:00:02", "eth1"))
+	assert.Nil(t, suite.store.AddMac("srv", "00:00:00:00:00:03", ""))
+
+	// Adding a MAC which is already there (in any format) updates it.
+	assert.Nil(t, suite.store.AddMac("srv", "00-00-00-00-00-03", "eth2"))
+
+	mis, err := suite.store.GetAll("srv")
+	assert.Nil(t, err)
+	assert.Equal(t, []MacIface{
+		{Mac: "00:00:00:00:00:01", Iface: "eth0"},
+		{Mac: "00:00:00:00:00:02", Iface: "eth1"},
+		{Mac: "00:00:00:00:00:03", Iface: "eth2"},
+	}, mis)
+
+	// The single pair methods see the primary.
+	mi, err := suite.store.Get("srv")
+	assert.Nil(t, err)
+	assert.Equal(t, MacIface{Mac: "00:00:00:00:00:01", Iface: "eth0"}, mi)
+
+	var seen [][]MacIface
+	assert.Nil(t, suite.store.ForEachAll(func(alias string, mis []MacIface) error {
+		seen = append(seen, mis)
+		return nil
+	}))
+	assert.Len(t, seen, 1)
+	assert.Len(t, seen[0], 3)
+
+	// Removing the primary promotes the next pair.
+	assert.Nil(t, suite.store.RemoveMac("srv", "00:00:00:00:00:01"))
+	mi, err = suite.store.Get("srv")
+	assert.Nil(t, err)
+	assert.Equal(t, MacIface{Mac: "00:00:00:00:00:02", Iface: "eth1"}, mi)
+
+	// Edit only touches the primary, the others survive a copy.
+	assert.Nil(t, suite.store.Edit("srv", func(mi *MacIface) error {
+		mi.Iface = "eth9"
+		return nil
+	}))
+	assert.Nil(t, suite.store.Copy("srv", "srv2"))
+	mis, err = suite.store.GetAll("srv2")
+	assert.Nil(t, err)
+	assert.Equal(t, []MacIface{
+		{Mac: "00:00:00:00:00:02", Iface: "eth9"},
+		{Mac: "00:00:00:00:00:03", Iface: "eth2"},
+	}, mis)
+
+	err = suite.store.RemoveMac("srv", "00:00:00:00:00:99")
+	assert.True(t, errors.Is(err, ErrMACNotFound))
+	assert.Nil(t, suite.store.RemoveMac("srv", "00:00:00:00:00:03"))
+	err = suite.store.RemoveMac("srv", "00:00:00:00:00:02")
+	assert.True(t, errors.Is(err, ErrLastMAC))
+
+	err = suite.store.AddMac("missing", "00:00:00:00:00:01", "")
+	assert.True(t, errors.Is(err, ErrAliasNotFound))
+	err = suite.store.RemoveMac("missing", "00:00:00:00:00:01")
+	assert.True(t, errors.Is(err, ErrAliasNotFound))
+	_, err = suite.store.GetAll("missing")
+	assert.True(t, errors.Is(err, ErrAliasNotFound))
+
+	// Add replaces all of the pairs.
+	assert.Nil(t, suite.store.Add("srv2", "00:00:00:00:00:04", ""))
+	mis, err = suite.store.GetAll("srv2")
+	assert.Nil(t, err)
+	assert.Equal(t, []MacIface{{Mac: "00:00:00:00:00:04", Iface: ""}}, mis)
+}
+
+// Validates setting the host of the pairs of an alias.
+func (suite *StoreTests) TestSetHost() {
+	t := suite.T()
+
+	assert.Nil(t, suite.store.Add("srv", "00:00:00:00:00:01", "eth0"))
+	assert.Nil(t, suite.store.AddMac("srv", "00:00:00:00:00:02", ""))
+
+	// No MAC sets the host of the primary.
+	assert.Nil(t, suite.store.SetHost("srv", "", "srv.lan"))
+	assert.Nil(t, suite.store.SetHost("srv", "00-00-00-00-00-02", "10.0.0.2"))
+
+	// The host is kept when the interface of a pair changes.
+	assert.Nil(t, suite.store.AddMac("srv", "00:00:00:00:00:02", "eth1"))
+
+	mis, err := suite.store.GetAll("srv")
+	assert.Nil(t, err)
+	assert.Equal(t, []MacIface{
+		{Mac: "00:00:00:00:00:01", Iface: "eth0", Host: "srv.lan"},
+		{Mac: "00:00:00:00:00:02", Iface: "eth1", Host: "10.0.0.2"},
+	}, mis)
+
+	// Single pairs keep their host too.
+	assert.Nil(t, suite.store.Add("one", "00:00:00:00:00:03", ""))
+	assert.Nil(t, suite.store.SetHost("one", "", "one.lan"))
+	if suite.kind != StoreMemory {
+		assert.Nil(t, suite.store.Close())
+		suite.store, err = OpenStore(suite.kind, filepath.Join(suite.dir, "aliases"))
+		assert.Nil(t, err)
+	}
+	mi, err := suite.store.Get("one")
+	assert.Nil(t, err)
+	assert.Equal(t, "one.lan", mi.Host)
+
+	// An empty host clears it.
+	assert.Nil(t, suite.store.SetHost("one", "", ""))
+	mi, err = suite.store.Get("one")
+	assert.Nil(t, err)
+	assert.Equal(t, "", mi.Host)
+
+	err = suite.store.SetHost("srv", "00:00:00:00:00:99", "x")
+	assert.True(t, errors.Is(err, ErrMACNotFound))
+	err = suite.store.SetHost("missing", "", "x")
+	assert.True(t, errors.Is(err, ErrAliasNotFound))
+}
+
+// Validates that entries survive closing and re-opening the store.
+func (suite *StoreTests) TestPersistence() {
+	t := suite.T()
+	if suite.kind == StoreMemory {
+		t.Skip("memory store is not persistent")
+	}
+
+	assert.Nil(t, suite.store.Add("one", "00:00:00:00:00:01", "eth0"))
+	assert.Nil(t, suite.store.Close())
+
+	var err error
+	suite.store, err = OpenStore(suite.kind, filepath.Join(suite.dir, "aliases"))
+	assert.Nil(t, err)
+
+	mi, err := suite.store.Get("one")
+	assert.Nil(t, err)
+	assert.Equal(t, MacIface{Mac: "00:00:00:00:00:01", Iface: "eth0"}, mi)
+
+	// As do aliases with several pairs.
+	assert.Nil(t, suite.store.AddMac("one", "00:00:00:00:00:02", "eth1"))
+	assert.Nil(t, suite.store.Close())
+	suite.store, err = OpenStore(suite.kind, filepath.Join(suite.dir, "aliases"))
+	assert.Nil(t, err)
+
+	mis, err := suite.store.GetAll("one")
+	assert.Nil(t, err)
+	assert.Equal(t, []MacIface{{Mac: "00:00:00:00:00:01", Iface: "eth0"}, {Mac: "00:00:00:00:00:02", Iface: "eth1"}}, mis)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Validates that JSON files written before aliases could hold several MAC
+// addresses are read, and that single pairs are still written the old way.
+func TestJSONStoreMigration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wol-json")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "aliases.json")
+	old := `{"one": {"mac": "00:00:00:00:00:01", "iface": "eth0"}}`
+	assert.Nil(t, ioutil.WriteFile(path, []byte(old), 0600))
+
+	store, err := LoadJSONStore(path)
+	assert.Nil(t, err)
+	mis, err := store.GetAll("one")
+	assert.Nil(t, err)
+	assert.Equal(t, []MacIface{{Mac: "00:00:00:00:00:01", Iface: "eth0"}}, mis)
+
+	assert.Nil(t, store.Add("two", "00:00:00:00:00:02", ""))
+	assert.Nil(t, store.AddMac("one", "00:00:00:00:00:03", "eth1"))
+
+	var raw map[string]json.RawMessage
+	bs, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(bs, &raw))
+	assert.Equal(t, byte('['), raw["one"][0])
+	assert.Equal(t, byte('{'), raw["two"][0])
+
+	// An alias without any addresses is rejected.
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"one": []}`), 0600))
+	_, err = LoadJSONStore(path)
+	assert.NotNil(t, err)
+}
+
+// Validates that the aliases in memory are left alone when they can not be
+// written to disk.
+func TestJSONStoreFailedSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wol-json")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "aliases.json")
+	store, err := LoadJSONStore(path)
+	assert.Nil(t, err)
+	assert.Nil(t, store.Add("one", "00:00:00:00:00:01", "eth0"))
+
+	// The file can not be replaced once it is a directory.
+	assert.Nil(t, os.Remove(path))
+	assert.Nil(t, os.Mkdir(path, 0700))
+	for _, fn := range []func() error{
+		func() error { return store.Rename("one", "two") },
+		func() error { return store.Copy("one", "two") },
+		func() error { return store.Del("one") },
+		func() error { return store.AddMac("one", "00:00:00:00:00:02", "") },
+		func() error {
+			return store.Edit("one", func(mi *MacIface) error { mi.Iface = "eth1"; return nil })
+		},
+	} {
+		assert.NotNil(t, fn())
+		list, err := store.List()
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]MacIface{"one": {Mac: "00:00:00:00:00:01", Iface: "eth0"}}, list)
+	}
+}
+
+// Validates a user store layered over a system-wide one.
+func TestLayeredStore(t *testing.T) {
+	user, system := NewMemStore(), NewMemStore()
+	assert.Nil(t, system.Add("nas", "00:00:00:00:00:01", ""))
+	assert.Nil(t, system.Add("printer", "00:00:00:00:00:02", "eth0"))
+	assert.Nil(t, user.Add("printer", "00:00:00:00:00:03", ""))
+	assert.Nil(t, user.Add("laptop", "00:00:00:00:00:04", ""))
+
+	store := NewLayered(user, system)
+	defer store.Close()
+
+	// User entries override the system ones of the same name.
+	list, err := store.List()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]MacIface{
+		"laptop":  {Mac: "00:00:00:00:00:04", Iface: ""},
+		"nas":     {Mac: "00:00:00:00:00:01", Iface: ""},
+		"printer": {Mac: "00:00:00:00:00:03", Iface: ""},
+	}, list)
+
+	for _, tc := range []struct {
+		alias, layer string
+	}{
+		{"laptop", LayerUser},
+		{"nas", LayerSystem},
+		{"printer", LayerUser},
+	} {
+		layer, err := store.Layer(tc.alias)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.layer, layer, tc.alias)
+	}
+	_, err = store.Layer("missing")
+	assert.True(t, errors.Is(err, ErrAliasNotFound))
+
+	// System aliases can be copied and overridden, but not removed or renamed.
+	assert.True(t, errors.Is(store.Del("nas"), ErrReadOnly))
+	assert.True(t, errors.Is(store.Rename("nas", "storage"), ErrReadOnly))
+	assert.True(t, errors.Is(store.Copy("laptop", "nas"), ErrAliasExists))
+	assert.Nil(t, store.Copy("nas", "storage"))
+	assert.Nil(t, store.AddMac("nas", "00:00:00:00:00:05", "eth1"))
+
+	mis, err := user.GetAll("nas")
+	assert.Nil(t, err)
+	assert.Equal(t, []MacIface{{Mac: "00:00:00:00:00:01", Iface: ""}, {Mac: "00:00:00:00:00:05", Iface: "eth1"}}, mis)
+	mis, err = system.GetAll("nas")
+	assert.Nil(t, err)
+	assert.Len(t, mis, 1)
+
+	// Removing the override uncovers the system alias again.
+	assert.Nil(t, store.Del("printer"))
+	mi, err := store.Get("printer")
+	assert.Nil(t, err)
+	assert.Equal(t, MacIface{Mac: "00:00:00:00:00:02", Iface: "eth0"}, mi)
+
+	assert.Nil(t, store.Edit("printer", func(mi *MacIface) error {
+		mi.Iface = "eth9"
+		return nil
+	}))
+	mi, err = user.Get("printer")
+	assert.Nil(t, err)
+	assert.Equal(t, MacIface{Mac: "00:00:00:00:00:02", Iface: "eth9"}, mi)
+
+	// Setting the host of a system alias overrides it too.
+	assert.Nil(t, system.Add("router", "00:00:00:00:00:06", ""))
+	assert.Nil(t, store.SetHost("router", "", "router.lan"))
+	mi, err = user.Get("router")
+	assert.Nil(t, err)
+	assert.Equal(t, "router.lan", mi.Host)
+	mi, err = system.Get("router")
+	assert.Nil(t, err)
+	assert.Equal(t, "", mi.Host)
+
+	// Hosts are copied along with the pairs.
+	assert.Nil(t, system.SetHost("nas", "", "nas.lan"))
+	assert.Nil(t, system.Add("backup", "00:00:00:00:00:07", ""))
+	assert.Nil(t, system.SetHost("backup", "", "backup.lan"))
+	assert.Nil(t, store.Copy("backup", "backup2"))
+	mi, err = user.Get("backup2")
+	assert.Nil(t, err)
+	assert.Equal(t, "backup.lan", mi.Host)
+}
+
+func TestOpenStoreUnknown(t *testing.T) {
+	_, err := OpenStore("foobar", "")
+	assert.NotNil(t, err)
+}
+
+func TestRunStoreSuites(t *testing.T) {
+	for _, kind := range []string{StoreBolt, StoreJSON, StoreMemory} {
+		suite.Run(t, &StoreTests{kind: kind})
+	}
+}
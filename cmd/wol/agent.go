@@ -0,0 +1,522 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sabhiram/go-wol/aliases"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Agents are small always-on boxes on each LAN which send magic packets on
+// behalf of a central `wol server` (or the CLI). Both speak the same API, so
+// the CLI can be pointed at either:
+//
+//	POST /v1/wake    {"target": "<alias>", "dry_run": false}, answered with a
+//	                 wakeResponse
+//	POST /v1/agents  {"name", "url", "aliases"}, agents registering (server)
+//	GET  /v1/agents  the agents known to the server
+//
+// Every request carries the shared secret as `Authorization: Bearer <token>`.
+const (
+	apiWake   = "/v1/wake"
+	apiAgents = "/v1/agents"
+
+	defaultListenAddr = ":8420"
+
+	// agentHeartbeat is how often agents register with the server, they are
+	// forgotten after missing a few.
+	agentHeartbeat = 30 * time.Second
+	agentTTL       = 3 * agentHeartbeat
+
+	httpTimeout = 10 * time.Second
+
+	// maxRequestBody is the largest request body read, registrations of
+	// agents with thousands of aliases fit easily.
+	maxRequestBody = 1 << 20
+)
+
+var (
+	// storeOpener opens the alias store as configured on the command line.
+	// Agents open it for every request instead of holding it open.
+	storeOpener func() (aliases.Store, error)
+
+	// configAgents holds the `[agents.<name>]` sections of the config file.
+	configAgents map[string]map[string]string
+
+	httpClient = &http.Client{Timeout: httpTimeout}
+
+	errRemotePcap = errors.New("--pcap can not be used when waking through a server or agent")
+)
+
+// wakeRequest is the body of a request to wake `Target`, on behalf of `User`
+// which is passed on to the webhooks. For a `DryRun` the agent resolves the
+// target and replies with the packets it would have sent.
+type wakeRequest struct {
+	Target string `json:"target"`
+	User   string `json:"user,omitempty"`
+	DryRun bool   `json:"dry_run,omitempty"`
+}
+
+// wakeResponse is the reply to a wakeRequest, and to any failed request.
+type wakeResponse struct {
+	Agent   string       `json:"agent,omitempty"`
+	Results []wakeResult `json:"results,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// agentInfo describes an agent and the aliases it is responsible for.
+type agentInfo struct {
+	Name    string   `json:"name"`
+	URL     string   `json:"url"`
+	Aliases []string `json:"aliases"`
+	Static  bool     `json:"static,omitempty"`
+
+	token string
+	seen  time.Time
+}
+
+// handles reports whether the agent is responsible for `alias`.
+func (a *agentInfo) handles(alias string) bool {
+	for _, name := range a.Aliases {
+		if name == alias {
+			return true
+		}
+	}
+	return false
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// requireToken only lets requests through to `h` which carry `token`.
+func requireToken(token string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, wakeResponse{Error: "invalid or missing token"})
+			return
+		}
+		h(w, r)
+	}
+}
+
+// readJSON decodes the body of `r` into `v`, failing for bodies larger than
+// maxRequestBody.
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	return json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(v)
+}
+
+// writeJSON replies to a request with `v` encoded as JSON.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// postJSON sends `body` to `url` and decodes the JSON reply into `reply`, if
+// not nil. Replies other than 2xx are turned into errors.
+func postJSON(client *http.Client, url, token string, body, reply interface{}) error {
+	bs, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(bs))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		var failed wakeResponse
+		if json.NewDecoder(resp.Body).Decode(&failed) == nil && len(failed.Error) > 0 {
+			return fmt.Errorf("%s: %s", url, failed.Error)
+		}
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	if reply == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(reply)
+}
+
+// remoteWake asks the agent or server at `base` to wake the target of `req`.
+func remoteWake(client *http.Client, base, token string, req wakeRequest) (wakeResponse, error) {
+	var resp wakeResponse
+	err := postJSON(client, strings.TrimSuffix(base, "/")+apiWake, token, req, &resp)
+	return resp, err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// agent wakes aliases from its own store on behalf of the server.
+type agent struct {
+	name  string
+	token string
+	open  func() (aliases.Store, error)
+}
+
+func (a *agent) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(apiWake, requireToken(a.token, a.handleWake))
+	return mux
+}
+
+func (a *agent) handleWake(w http.ResponseWriter, r *http.Request) {
+	var req wakeRequest
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, wakeResponse{Error: "use POST"})
+		return
+	}
+	if err := readJSON(w, r, &req); err != nil || len(req.Target) == 0 {
+		writeJSON(w, http.StatusBadRequest, wakeResponse{Error: "expected {\"target\": \"<alias>\"}"})
+		return
+	}
+
+	store, err := a.open()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, wakeResponse{Agent: a.name, Error: err.Error()})
+		return
+	}
+	defer store.Close()
+
+	// Failing to wake is still a complete answer, the results say why.
+	resp := wakeResponse{Agent: a.name}
+	dryRun := req.DryRun || cliFlags.DryRun
+	resp.Results, err = wakeInNetns(req.Target, store, dryRun)
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	writeJSON(w, http.StatusOK, resp)
+
+	// Answer before the webhooks, which may be retried for a while.
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	fireWakeWebhooks(dryRun, newWakeEvent(sourceAgent, req.User, req.Target, resp.Results, err))
+}
+
+// info returns the registration of the agent reachable at `url`.
+func (a *agent) info(url string) (agentInfo, error) {
+	info := agentInfo{Name: a.name, URL: url, Aliases: []string{}}
+
+	store, err := a.open()
+	if err != nil {
+		return info, err
+	}
+	defer store.Close()
+
+	err = store.ForEachAll(func(alias string, _ []aliases.MacIface) error {
+		info.Aliases = append(info.Aliases, alias)
+		return nil
+	})
+	return info, err
+}
+
+// register tells the server at `server` about the agent reachable at `url`.
+func (a *agent) register(client *http.Client, server, url string) error {
+	info, err := a.info(url)
+	if err != nil {
+		return err
+	}
+	return postJSON(client, strings.TrimSuffix(server, "/")+apiAgents, a.token, info, nil)
+}
+
+// heartbeat registers with the server every agentHeartbeat until `stop` is
+// closed, so that it picks up changes to the aliases.
+func (a *agent) heartbeat(client *http.Client, server, url string, stop <-chan struct{}) {
+	ticker := time.NewTicker(agentHeartbeat)
+	defer ticker.Stop()
+	for {
+		if err := a.register(client, server, url); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to register with %s: %v\n", server, err)
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// dispatcher is the central server, which routes wakes to the agent that is
+// responsible for the alias.
+type dispatcher struct {
+	token  string
+	client *http.Client
+	ttl    time.Duration
+
+	mtx    sync.Mutex
+	agents map[string]*agentInfo
+}
+
+// newDispatcher returns a dispatcher which knows about the `static` agents from
+// the config file, in addition to those which register themselves. Static
+// agents use `token` unless they have their own.
+func newDispatcher(token string, static map[string]map[string]string) (*dispatcher, error) {
+	d := &dispatcher{
+		token:  token,
+		client: httpClient,
+		ttl:    agentTTL,
+		agents: map[string]*agentInfo{},
+	}
+	for name, values := range static {
+		if len(values["url"]) == 0 {
+			return nil, fmt.Errorf("agent %q has no url in the config", name)
+		}
+		info := &agentInfo{Name: name, URL: values["url"], Static: true, token: token}
+		if t, ok := values["token"]; ok {
+			info.token = t
+		}
+		info.Aliases = splitList(values["aliases"])
+		d.agents[name] = info
+	}
+	return d, nil
+}
+
+func (d *dispatcher) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(apiWake, requireToken(d.token, d.handleWake))
+	mux.HandleFunc(apiAgents, requireToken(d.token, d.handleAgents))
+	return mux
+}
+
+func (d *dispatcher) handleWake(w http.ResponseWriter, r *http.Request) {
+	var req wakeRequest
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, wakeResponse{Error: "use POST"})
+		return
+	}
+	if err := readJSON(w, r, &req); err != nil || len(req.Target) == 0 {
+		writeJSON(w, http.StatusBadRequest, wakeResponse{Error: "expected {\"target\": \"<alias>\"}"})
+		return
+	}
+
+	info, err := d.route(req.Target)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, wakeResponse{Error: err.Error()})
+		return
+	}
+	resp, err := d.forward(info, req)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, wakeResponse{Agent: info.Name, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (d *dispatcher) handleAgents(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, d.list())
+
+	case http.MethodPost:
+		var info agentInfo
+		if err := readJSON(w, r, &info); err != nil || len(info.Name) == 0 {
+			writeJSON(w, http.StatusBadRequest, wakeResponse{Error: "expected {\"name\", \"url\", \"aliases\"}"})
+			return
+		}
+		if u, err := url.Parse(info.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			writeJSON(w, http.StatusBadRequest, wakeResponse{Error: "agent url must be http or https"})
+			return
+		}
+		d.register(info)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, wakeResponse{Error: "use GET or POST"})
+	}
+}
+
+// register adds or refreshes an agent. Agents which register themselves use
+// the server's token.
+func (d *dispatcher) register(info agentInfo) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.expire()
+	info.Static, info.token, info.seen = false, d.token, time.Now()
+	d.agents[info.Name] = &info
+}
+
+// expire forgets the agents which stopped registering, so that agents which
+// come and go under new names are not kept forever. The lock must be held.
+func (d *dispatcher) expire() {
+	for name, info := range d.agents {
+		if !info.Static && time.Since(info.seen) >= d.ttl {
+			delete(d.agents, name)
+		}
+	}
+}
+
+// list returns the live agents sorted by name.
+func (d *dispatcher) list() []agentInfo {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.expire()
+	out := []agentInfo{}
+	for _, info := range d.agents {
+		out = append(out, *info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// route returns the agent responsible for `target`. When several are, the
+// first by name is used.
+func (d *dispatcher) route(target string) (agentInfo, error) {
+	for _, info := range d.list() {
+		if info.handles(target) {
+			return info, nil
+		}
+	}
+	return agentInfo{}, fmt.Errorf("no agent is responsible for %s", target)
+}
+
+// forward passes the wake request `req` on to the agent `info`.
+func (d *dispatcher) forward(info agentInfo, req wakeRequest) (wakeResponse, error) {
+	resp, err := remoteWake(d.client, info.URL, info.token, req)
+	if err != nil {
+		return resp, err
+	}
+	resp.Agent = info.Name
+	return resp, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// dispatchWake wakes `target` through the `--server` if one is set, or through
+// a configured agent responsible for it. It reports false if `target` is to be
+// woken locally. A dry run is passed on to the agent, while a pcap file can
+// only be written locally.
+func dispatchWake(target string) ([]wakeResult, bool, error) {
+	req := wakeRequest{Target: target, User: wakeUser(), DryRun: cliFlags.DryRun}
+	var resp wakeResponse
+	var err error
+	switch {
+	case cliFlags.Server != "":
+		if len(cliFlags.Pcap) > 0 {
+			return nil, true, errRemotePcap
+		}
+		resp, err = remoteWake(httpClient, cliFlags.Server, cliFlags.Token, req)
+
+	case len(configAgents) > 0:
+		d, derr := newDispatcher(cliFlags.Token, configAgents)
+		if derr != nil {
+			return nil, true, derr
+		}
+		info, rerr := d.route(target)
+		if rerr != nil {
+			return nil, false, nil
+		}
+		if len(cliFlags.Pcap) > 0 {
+			return nil, true, fmt.Errorf("%s is woken through agent %s: %w", target, info.Name, errRemotePcap)
+		}
+		resp, err = d.forward(info, req)
+
+	default:
+		return nil, false, nil
+	}
+
+	if err == nil && len(resp.Error) > 0 {
+		err = fmt.Errorf("agent %s: %s", resp.Agent, resp.Error)
+	}
+
+	// The packets of a dry run only come back hex encoded.
+	for i, r := range resp.Results {
+		resp.Results[i].packet, _ = hex.DecodeString(r.Packet)
+	}
+	return resp.Results, true, err
+}
+
+// Run the agent command.
+func agentCmd(args []string, store aliases.Store) error {
+	if len(args) < 2 {
+		return usageError("agent command requires a <name> and the <url> the server reaches it at")
+	}
+	if len(cliFlags.Token) == 0 {
+		return usageError("agent command requires a --token shared with the server")
+	}
+	name, agentURL := args[0], args[1]
+
+	// Listen on the port of the url unless told otherwise.
+	u, err := url.Parse(agentURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid agent url %q", agentURL)
+	}
+	listen := ":" + u.Port()
+	if len(u.Port()) == 0 {
+		listen = defaultListenAddr
+	}
+	if len(args) > 2 {
+		listen = args[2]
+	}
+
+	a := &agent{name: name, token: cliFlags.Token, open: storeOpener}
+	if cliFlags.Server != "" {
+		stop := make(chan struct{})
+		defer close(stop)
+		go a.heartbeat(httpClient, cliFlags.Server, agentURL, stop)
+	}
+	return serve(listen, a.handler(), fmt.Sprintf("Agent %s", name))
+}
+
+// Run the server command.
+func serverCmd(args []string, store aliases.Store) error {
+	if len(cliFlags.Token) == 0 {
+		return usageError("server command requires a --token shared with the agents")
+	}
+	listen := defaultListenAddr
+	if len(args) > 0 {
+		listen = args[0]
+	}
+
+	d, err := newDispatcher(cliFlags.Token, configAgents)
+	if err != nil {
+		return err
+	}
+	return serve(listen, d.handler(), "Server")
+}
+
+// serve runs the HTTP server for `handler` on `listen` until it fails.
+func serve(listen string, handler http.Handler, what string) error {
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%s listening on %s\n", what, ln.Addr())
+
+	srv := &http.Server{
+		Handler:      handler,
+		ReadTimeout:  httpTimeout,
+		WriteTimeout: 2 * httpTimeout,
+	}
+	return srv.Serve(ln)
+}
@@ -0,0 +1,212 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// testAgent starts an agent named `name` which wakes "foo" by sending to a
+// local UDP listener, which is returned.
+func testAgent(t *testing.T, name, token string) (*agent, *httptest.Server, *net.UDPConn) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.Nil(t, err)
+
+	cliFlags.BroadcastIP = "127.0.0.1"
+	cliFlags.UDPPort = strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+
+	a := &agent{name: name, token: token, open: func() (aliases.Store, error) {
+		store := aliases.NewMemStore()
+		return store, store.Add("foo", "00:11:22:33:44:55", "")
+	}}
+	return a, httptest.NewServer(a.handler()), conn
+}
+
+// Validates waking an alias through an agent.
+func TestAgentWake(t *testing.T) {
+	saved := cliFlags
+	defer func() { cliFlags = saved }()
+
+	_, srv, conn := testAgent(t, "lab", "s3cret")
+	defer srv.Close()
+	defer conn.Close()
+
+	resp, err := remoteWake(httpClient, srv.URL, "s3cret", wakeRequest{Target: "foo"})
+	assert.Nil(t, err)
+	assert.Equal(t, "lab", resp.Agent)
+	assert.Equal(t, "", resp.Error)
+	assert.Len(t, resp.Results, 1)
+	assert.Equal(t, "00:11:22:33:44:55", resp.Results[0].Mac)
+	assert.Equal(t, 102, resp.Results[0].BytesSent)
+
+	bs := make([]byte, 1024)
+	assert.Nil(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := conn.ReadFromUDP(bs)
+	assert.Nil(t, err)
+	assert.Equal(t, 102, n)
+
+	// Failures to wake are reported in the response.
+	resp, err = remoteWake(httpClient, srv.URL, "s3cret", wakeRequest{Target: "not-a-mac"})
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", resp.Error)
+
+	for _, token := range []string{"", "wrong"} {
+		_, err = remoteWake(httpClient, srv.URL, token, wakeRequest{Target: "foo"})
+		assert.NotNil(t, err)
+		assert.True(t, strings.Contains(err.Error(), "invalid or missing token"), err.Error())
+	}
+
+	// The token must come as a bearer token, and bodies are limited in size.
+	for _, tc := range []struct {
+		auth, body string
+		code       int
+	}{
+		{"s3cret", `{"target": "foo"}`, http.StatusUnauthorized},
+		{"Basic s3cret", `{"target": "foo"}`, http.StatusUnauthorized},
+		{"Bearer s3cret", `{"target": "foo", "user": "` + strings.Repeat("a", maxRequestBody) + `"}`, http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+apiWake, strings.NewReader(tc.body))
+		assert.Nil(t, err)
+		req.Header.Set("Authorization", tc.auth)
+		resp, err := httpClient.Do(req)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, tc.code, resp.StatusCode, tc.auth)
+	}
+}
+
+// Validates that the server routes wakes to the agent responsible for them.
+func TestDispatcher(t *testing.T) {
+	saved := cliFlags
+	defer func() { cliFlags = saved }()
+
+	a, agentSrv, conn := testAgent(t, "lab", "s3cret")
+	defer agentSrv.Close()
+	defer conn.Close()
+
+	d, err := newDispatcher("s3cret", map[string]map[string]string{
+		"office": {"url": "http://127.0.0.1:1", "aliases": "printer, nas"},
+	})
+	assert.Nil(t, err)
+	srv := httptest.NewServer(d.handler())
+	defer srv.Close()
+
+	// Agents can only register with the right token.
+	assert.NotNil(t, (&agent{name: "lab", token: "wrong", open: a.open}).register(httpClient, srv.URL, agentSrv.URL))
+	assert.Nil(t, a.register(httpClient, srv.URL, agentSrv.URL))
+	assert.Len(t, d.list(), 2)
+
+	resp, err := remoteWake(httpClient, srv.URL, "s3cret", wakeRequest{Target: "foo"})
+	assert.Nil(t, err)
+	assert.Equal(t, "lab", resp.Agent)
+	assert.Len(t, resp.Results, 1)
+
+	for _, tc := range []struct {
+		target, message string
+	}{
+		{"bar", "no agent is responsible for bar"},
+		// The static agent is not reachable.
+		{"nas", "127.0.0.1:1"},
+	} {
+		_, err := remoteWake(httpClient, srv.URL, "s3cret", wakeRequest{Target: tc.target})
+		assert.NotNil(t, err, tc.target)
+		assert.True(t, strings.Contains(err.Error(), tc.message), err.Error())
+	}
+
+	// Agents which stop registering are forgotten, static ones are kept.
+	d.ttl = 0
+	_, err = d.route("foo")
+	assert.NotNil(t, err)
+	assert.Len(t, d.agents, 1)
+	info, err := d.route("printer")
+	assert.Nil(t, err)
+	assert.Equal(t, "office", info.Name)
+
+	_, err = newDispatcher("s3cret", map[string]map[string]string{"office": {}})
+	assert.NotNil(t, err)
+}
+
+// Validates that the cli wakes through the server or a configured agent.
+func TestDispatchWake(t *testing.T) {
+	saved, savedAgents := cliFlags, configAgents
+	defer func() { cliFlags, configAgents = saved, savedAgents }()
+
+	_, srv, conn := testAgent(t, "lab", "s3cret")
+	defer srv.Close()
+	defer conn.Close()
+
+	// Nothing is configured, wake locally.
+	configAgents = nil
+	_, remote, err := dispatchWake("foo")
+	assert.Nil(t, err)
+	assert.False(t, remote)
+
+	configAgents = map[string]map[string]string{
+		"lab": {"url": srv.URL, "token": "s3cret", "aliases": "foo"},
+	}
+	results, remote, err := dispatchWake("foo")
+	assert.Nil(t, err)
+	assert.True(t, remote)
+	assert.Len(t, results, 1)
+
+	_, remote, err = dispatchWake("bar")
+	assert.Nil(t, err)
+	assert.False(t, remote)
+
+	cliFlags.Server, cliFlags.Token = srv.URL, "s3cret"
+	_, remote, err = dispatchWake("not-a-mac")
+	assert.True(t, remote)
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "agent lab: "), err.Error())
+}
+
+// Validates that dry runs are passed on to the agent, which sends nothing,
+// and that a pcap file is never asked of one.
+func TestDispatchWakeDryRun(t *testing.T) {
+	saved, savedAgents := cliFlags, configAgents
+	defer func() { cliFlags, configAgents = saved, savedAgents }()
+
+	_, srv, conn := testAgent(t, "lab", "s3cret")
+	defer srv.Close()
+	defer conn.Close()
+
+	configAgents = map[string]map[string]string{
+		"lab": {"url": srv.URL, "token": "s3cret", "aliases": "foo"},
+	}
+	cliFlags.DryRun = true
+	for _, server := range []string{"", srv.URL} {
+		cliFlags.Server, cliFlags.Token = server, "s3cret"
+		results, remote, err := dispatchWake("foo")
+		assert.Nil(t, err)
+		assert.True(t, remote)
+		assert.Len(t, results, 1)
+		assert.True(t, results[0].DryRun)
+		assert.Equal(t, 0, results[0].Attempts)
+		assert.Equal(t, 102, len(results[0].packet))
+	}
+
+	// Nothing should have arrived at the listener.
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, _, err := conn.ReadFromUDP(make([]byte, 1024))
+	assert.NotNil(t, err)
+
+	cliFlags.DryRun, cliFlags.Pcap = false, "out.pcap"
+	for _, server := range []string{"", srv.URL} {
+		cliFlags.Server = server
+		_, remote, err := dispatchWake("foo")
+		assert.True(t, remote)
+		assert.True(t, errors.Is(err, errRemotePcap))
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"syscall"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// canBindToDevice is true where sockets can be bound to an interface, so that
+// interfaces without an IPv4 address can still be used to send from.
+const canBindToDevice = true
+
+// bindToDevice returns a net.Dialer control function which binds the socket
+// to the interface `iface` with SO_BINDTODEVICE. Before Linux 5.7 this needs
+// CAP_NET_RAW.
+func bindToDevice(iface string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var serr error
+		err := c.Control(func(fd uintptr) {
+			serr = syscall.BindToDevice(int(fd), iface)
+		})
+		if err == nil {
+			err = serr
+		}
+		if err != nil {
+			return fmt.Errorf("binding to interface %s: %w", iface, err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/sabhiram/go-wol/wol"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Validates sending a magic packet from a socket bound to the loopback device.
+func TestBindToDevice(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	lo := ""
+	interfaces, err := net.Interfaces()
+	assert.Nil(t, err)
+	for _, ief := range interfaces {
+		if ief.Flags&net.FlagLoopback != 0 {
+			lo = ief.Name
+		}
+	}
+	if len(lo) == 0 {
+		t.Skip("no loopback interface")
+	}
+
+	plan := &wakePlan{
+		device:     lo,
+		remoteAddr: conn.LocalAddr().(*net.UDPAddr),
+		packet:     make([]byte, wol.Size),
+	}
+	var res wakeResult
+	err = plan.send(&res)
+	if errors.Is(err, syscall.EPERM) {
+		t.Skip("binding to a device needs CAP_NET_RAW")
+	}
+	assert.Nil(t, err)
+	assert.Equal(t, wol.Size, res.BytesSent)
+
+	buf := make([]byte, 1024)
+	assert.Nil(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := conn.ReadFromUDP(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, wol.Size, n)
+
+	assert.NotNil(t, (&wakePlan{
+		device:     "fake-interface-0",
+		remoteAddr: conn.LocalAddr().(*net.UDPAddr),
+		packet:     make([]byte, wol.Size),
+	}).send(&res))
+}
+
+// Validates that interfaces without an IPv4 address are bound to instead.
+func TestPlanWakeBindToDevice(t *testing.T) {
+	saved := cliFlags
+	defer func() { cliFlags = saved }()
+
+	candidates, err := interfaceAddrs()
+	assert.Nil(t, err)
+	name := ""
+	for _, a := range candidates {
+		if a.IPNet == nil {
+			name = a.Name
+		}
+	}
+	if len(name) == 0 {
+		t.Skip("no interface without an address")
+	}
+
+	cliFlags.BroadcastIP = "127.0.0.1"
+	cliFlags.UDPPort = "9"
+	cliFlags.BroadcastInterface = name
+	res, plan, err := planWake("foo", aliases.MacIface{Mac: "00:11:22:33:44:55"})
+	assert.Nil(t, err)
+	assert.True(t, res.BindToDevice)
+	assert.Equal(t, name, res.Interface)
+	assert.Equal(t, name, plan.device)
+	assert.Nil(t, plan.localAddr)
+}
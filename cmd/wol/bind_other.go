@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"syscall"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// canBindToDevice is false as SO_BINDTODEVICE is Linux only, interfaces need
+// an IPv4 address to send from elsewhere.
+const canBindToDevice = false
+
+// bindToDevice is never called where canBindToDevice is false.
+func bindToDevice(iface string) func(network, address string, c syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/sabhiram/go-wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// validateMAC checks that `mac` can be used as the target of an alias and
+// returns it in its canonical (lower case, colon separated) form. Locally
+// administered addresses are accepted but come back with a warning since they
+// are often randomized and may not survive a reboot of the target.
+func validateMAC(mac string) (string, string, error) {
+	addr, err := wol.ParseMAC(mac)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch {
+	case addr.IsZero():
+		return "", "", fmt.Errorf("%w: %s is the all-zero MAC address", wol.ErrInvalidMAC, mac)
+	case addr.IsBroadcast():
+		return "", "", fmt.Errorf("%w: %s is the broadcast MAC address", wol.ErrInvalidMAC, mac)
+	case addr.IsMulticast():
+		return "", "", fmt.Errorf("%w: %s is a multicast MAC address", wol.ErrInvalidMAC, mac)
+	}
+
+	warning := ""
+	if addr.IsLocallyAdministered() {
+		warning = fmt.Sprintf("%s is a locally administered MAC address", mac)
+	}
+	return addr.String(), warning, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// checkEntry describes a problem found with a stored alias.
+type checkEntry struct {
+	Alias   string `json:"alias"`
+	Mac     string `json:"mac"`
+	Level   string `json:"level"`
+	Problem string `json:"problem"`
+}
+
+// checkReport is the result of the check command.
+type checkReport []checkEntry
+
+func (c checkReport) plain(w io.Writer) {
+	if len(c) == 0 {
+		fmt.Fprintf(w, "All aliases are valid\n")
+		return
+	}
+	for _, e := range c {
+		fmt.Fprintf(w, "    %s - %s: %s\n", e.Alias, e.Level, e.Problem)
+	}
+}
+
+func (c checkReport) table() ([]string, [][]string) {
+	rows := make([][]string, 0, len(c))
+	for _, e := range c {
+		rows = append(rows, []string{e.Alias, e.Mac, e.Level, e.Problem})
+	}
+	return []string{"ALIAS", "MAC", "LEVEL", "PROBLEM"}, rows
+}
+
+// errors returns the number of entries in the report which are errors.
+func (c checkReport) errors() int {
+	count := 0
+	for _, e := range c {
+		if e.Level == "error" {
+			count++
+		}
+	}
+	return count
+}
+
+// checkAliases validates every MAC address of every alias in the store.
+func checkAliases(store aliases.Store) (checkReport, error) {
+	report := checkReport{}
+	err := store.ForEachAll(func(alias string, mis []aliases.MacIface) error {
+		for _, mi := range mis {
+			canonical, warning, err := validateMAC(mi.Mac)
+			switch {
+			case err != nil:
+				report = append(report, checkEntry{alias, mi.Mac, "error", err.Error()})
+			case len(warning) > 0:
+				report = append(report, checkEntry{alias, mi.Mac, "warning", warning})
+			case canonical != mi.Mac:
+				report = append(report, checkEntry{alias, mi.Mac, "warning",
+					fmt.Sprintf("not in canonical form (%s)", canonical)})
+			}
+		}
+		return nil
+	})
+	return report, err
+}
+
+// Run the check command.
+func checkCmd(args []string, store aliases.Store) error {
+	report, err := checkAliases(store)
+	if err != nil {
+		return err
+	}
+	if err := writeOutput(os.Stdout, cliFlags.Output, report); err != nil {
+		return err
+	}
+	if n := report.errors(); n > 0 {
+		return fmt.Errorf("found %d invalid alias(es)", n)
+	}
+	return nil
+}
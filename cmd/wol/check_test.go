@@ -0,0 +1,62 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"testing"
+
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestValidateMAC(t *testing.T) {
+	for _, tc := range []struct {
+		mac, canonical string
+		warn, fail     bool
+	}{
+		{"00:11:22:AA:BB:CC", "00:11:22:aa:bb:cc", false, false},
+		{"00-11-22-aa-bb-cc", "00:11:22:aa:bb:cc", false, false},
+		{"0011.22aa.bbcc", "00:11:22:aa:bb:cc", false, false},
+		{"0-11-22-aa-bb-cc", "00:11:22:aa:bb:cc", false, false},
+		{"02:42:ac:11:00:02", "02:42:ac:11:00:02", true, false},
+		{"00:00:00:00:00:00", "", false, true},
+		{"ff:ff:ff:ff:ff:ff", "", false, true},
+		{"01:00:5e:00:00:01", "", false, true},
+		{"not-a-mac", "", false, true},
+	} {
+		canonical, warning, err := validateMAC(tc.mac)
+		assert.Equal(t, tc.canonical, canonical, tc.mac)
+		assert.Equal(t, tc.warn, len(warning) > 0, tc.mac)
+		assert.Equal(t, tc.fail, err != nil, tc.mac)
+		if tc.fail {
+			assert.Equal(t, exitInvalidMAC, exitCode(err), tc.mac)
+		}
+	}
+}
+
+func TestCheckAliases(t *testing.T) {
+	store := aliases.NewMemStore()
+	assert.Nil(t, store.Add("good", "00:11:22:aa:bb:cc", ""))
+	assert.Nil(t, store.Add("upper", "00:11:22:AA:BB:CC", ""))
+	assert.Nil(t, store.Add("local", "02:42:ac:11:00:02", ""))
+	assert.Nil(t, store.Add("zero", "00:00:00:00:00:00", ""))
+	assert.Nil(t, store.Add("garbage", "foobar", ""))
+
+	report, err := checkAliases(store)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(report))
+	assert.Equal(t, 2, report.errors())
+
+	levels := map[string]string{}
+	for _, e := range report {
+		levels[e.Alias] = e.Level
+	}
+	assert.Equal(t, map[string]string{
+		"garbage": "error",
+		"local":   "warning",
+		"upper":   "warning",
+		"zero":    "error",
+	}, levels)
+}
@@ -0,0 +1,419 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sabhiram/go-wol/aliases"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	// completeCmdName is the hidden command the completion scripts call back
+	// into to fetch dynamic values (alias and interface names).
+	completeCmdName = "__complete"
+)
+
+var (
+	// Values which can be completed for options that take a fixed set.
+	optionCompletions = map[string][]string{
+		"output": {outputPlain, outputTable, outputJSON, outputYAML},
+		"store":  {aliases.StoreBolt, aliases.StoreJSON, aliases.StoreMemory},
+	}
+
+	// Options whose value is a network interface, a file or a directory.
+	interfaceOptions = map[string]bool{"interface": true, "iface": true}
+	fileOptions      = map[string]bool{"config": true, "pcap": true}
+	dirOptions       = map[string]bool{"db-dir": true, "system-db-dir": true}
+
+	// Options which decide the aliases that are listed, these are passed on
+	// from the command line being completed to `wol __complete aliases`.
+	// The environment is inherited as is.
+	storeOptions = map[string]bool{
+		"db-dir":        true,
+		"db-name":       true,
+		"system-db-dir": true,
+		"store":         true,
+		"config":        true,
+		"profile":       true,
+	}
+
+	// Commands which take an alias as their argument.
+	aliasCommands = []string{"wake", "remove", "edit", "rename", "copy", "status"}
+
+	// Commands which take a file as their argument.
+	fileCommands = []string{"inspect"}
+
+	// Sub-command arguments which can be completed.
+	commandArgs = map[string][]string{
+		"alias":      {aliasAddMac, aliasRemoveMac},
+		"config":     {"show"},
+		"completion": {"bash", "zsh", "fish"},
+	}
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// completionOption describes an option for the completion generators.
+type completionOption struct {
+	short, long, description string
+	takesValue               bool
+}
+
+// flags returns the spellings of the option, some have no short one.
+func (o completionOption) flags() []string {
+	if len(o.short) == 0 {
+		return []string{"--" + o.long}
+	}
+	return []string{"-" + o.short, "--" + o.long}
+}
+
+// completionOptions returns all the valid options along with whether or not
+// they expect a value, which we find out from the cli parser.
+func completionOptions() []completionOption {
+	takesValue := map[string]bool{}
+	for _, o := range allOptions(newParser().Command.Group) {
+		_, isBool := o.Value().(bool)
+		takesValue[o.LongName] = !isBool
+	}
+
+	opts := make([]completionOption, 0, len(validOptions))
+	for _, o := range validOptions {
+		opts = append(opts, completionOption{o.short, o.long, o.description, takesValue[o.long]})
+	}
+	return opts
+}
+
+// storeFlags returns the short and long flags of the storeOptions.
+func storeFlags() []string {
+	var out []string
+	for _, o := range completionOptions() {
+		if storeOptions[o.long] {
+			out = append(out, o.flags()...)
+		}
+	}
+	return out
+}
+
+// writeCompletion writes the completion script for `shell` to `w`.
+func writeCompletion(w io.Writer, shell string) error {
+	switch strings.ToLower(shell) {
+	case "bash":
+		writeBashCompletion(w)
+	case "zsh":
+		writeZshCompletion(w)
+	case "fish":
+		writeFishCompletion(w)
+	default:
+		return fmt.Errorf("unsupported shell %q (expected one of: bash, zsh, fish)", shell)
+	}
+	return nil
+}
+
+// commandNames returns the names of all the visible commands.
+func commandNames() []string {
+	names := make([]string, 0, len(validCommands))
+	for _, c := range validCommands {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func writeBashCompletion(w io.Writer) {
+	var words, valueOpts []string
+	for _, o := range completionOptions() {
+		words = append(words, o.flags()...)
+		if o.takesValue {
+			valueOpts = append(valueOpts, o.flags()...)
+		}
+	}
+
+	var longs []string
+	for _, f := range storeFlags() {
+		if strings.HasPrefix(f, "--") {
+			longs = append(longs, f+"=*")
+		}
+	}
+
+	fmt.Fprintf(w, "# bash completion for wol\n")
+	fmt.Fprintf(w, "# Load with: source <(wol completion bash)\n\n")
+
+	// List the aliases of the store picked on the command line. Bash splits
+	// `--db-dir=dir` into three words.
+	fmt.Fprintf(w, "_wol_aliases() {\n")
+	fmt.Fprintf(w, "    local i args=()\n")
+	fmt.Fprintf(w, "    for (( i=1; i < COMP_CWORD; i++ )); do\n")
+	fmt.Fprintf(w, "        case \"${COMP_WORDS[i]}\" in\n")
+	fmt.Fprintf(w, "            %s)\n", strings.Join(storeFlags(), "|"))
+	fmt.Fprintf(w, "                if [[ \"${COMP_WORDS[i+1]}\" == \"=\" ]]; then\n")
+	fmt.Fprintf(w, "                    args+=( \"${COMP_WORDS[i]}\" \"${COMP_WORDS[i+2]/#\\~/$HOME}\" ); (( i += 2 ))\n")
+	fmt.Fprintf(w, "                else\n")
+	fmt.Fprintf(w, "                    args+=( \"${COMP_WORDS[i]}\" \"${COMP_WORDS[i+1]/#\\~/$HOME}\" ); (( i++ ))\n")
+	fmt.Fprintf(w, "                fi ;;\n")
+	fmt.Fprintf(w, "            %s) args+=( \"${COMP_WORDS[i]}\" ) ;;\n", strings.Join(longs, "|"))
+	fmt.Fprintf(w, "        esac\n")
+	fmt.Fprintf(w, "    done\n")
+	fmt.Fprintf(w, "    wol \"${args[@]}\" %s aliases 2>/dev/null\n", completeCmdName)
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "_wol() {\n")
+	fmt.Fprintf(w, "    local cur prev cmd i\n")
+	fmt.Fprintf(w, "    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(w, "    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n\n")
+
+	// Complete the values of options which take one.
+	fmt.Fprintf(w, "    case \"$prev\" in\n")
+	for _, o := range completionOptions() {
+		if !o.takesValue {
+			continue
+		}
+		pattern := strings.Join(o.flags(), "|")
+		switch {
+		case interfaceOptions[o.long]:
+			fmt.Fprintf(w, "        %s)\n            COMPREPLY=( $(compgen -W \"$(wol %s interfaces 2>/dev/null)\" -- \"$cur\") )\n            return ;;\n", pattern, completeCmdName)
+		case len(optionCompletions[o.long]) > 0:
+			fmt.Fprintf(w, "        %s)\n            COMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") )\n            return ;;\n", pattern, strings.Join(optionCompletions[o.long], " "))
+		case fileOptions[o.long]:
+			fmt.Fprintf(w, "        %s)\n            COMPREPLY=( $(compgen -f -- \"$cur\") )\n            return ;;\n", pattern)
+		case dirOptions[o.long]:
+			fmt.Fprintf(w, "        %s)\n            COMPREPLY=( $(compgen -d -- \"$cur\") )\n            return ;;\n", pattern)
+		default:
+			fmt.Fprintf(w, "        %s)\n            return ;;\n", pattern)
+		}
+	}
+	fmt.Fprintf(w, "    esac\n\n")
+
+	fmt.Fprintf(w, "    if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(w, "        COMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") )\n", strings.Join(words, " "))
+	fmt.Fprintf(w, "        return\n")
+	fmt.Fprintf(w, "    fi\n\n")
+
+	// Find the command, skipping over options and their values.
+	fmt.Fprintf(w, "    cmd=\"\"\n")
+	fmt.Fprintf(w, "    for (( i=1; i < COMP_CWORD; i++ )); do\n")
+	fmt.Fprintf(w, "        case \"${COMP_WORDS[i]}\" in\n")
+	fmt.Fprintf(w, "            %s)\n", strings.Join(valueOpts, "|"))
+	fmt.Fprintf(w, "                (( i++ ))\n")
+	fmt.Fprintf(w, "                [[ \"${COMP_WORDS[i]}\" == \"=\" ]] && (( i++ )) ;;\n")
+	fmt.Fprintf(w, "            -*) ;;\n")
+	fmt.Fprintf(w, "            *) cmd=\"${COMP_WORDS[i]}\"; break ;;\n")
+	fmt.Fprintf(w, "        esac\n")
+	fmt.Fprintf(w, "    done\n\n")
+
+	fmt.Fprintf(w, "    case \"$cmd\" in\n")
+	fmt.Fprintf(w, "        \"\")\n            COMPREPLY=( $(compgen -W \"%s $(_wol_aliases)\" -- \"$cur\") ) ;;\n", strings.Join(commandNames(), " "))
+	fmt.Fprintf(w, "        %s)\n            COMPREPLY=( $(compgen -W \"$(_wol_aliases)\" -- \"$cur\") ) ;;\n", strings.Join(aliasCommands, "|"))
+	fmt.Fprintf(w, "        %s)\n            COMPREPLY=( $(compgen -f -- \"$cur\") ) ;;\n", strings.Join(fileCommands, "|"))
+	for _, cmd := range sortedKeys(commandArgs) {
+		fmt.Fprintf(w, "        %s)\n            COMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") ) ;;\n", cmd, strings.Join(commandArgs[cmd], " "))
+	}
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "complete -F _wol wol\n")
+}
+
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprintf(w, "#compdef wol\n")
+	fmt.Fprintf(w, "# zsh completion for wol\n")
+	fmt.Fprintf(w, "# Load with: wol completion zsh > \"${fpath[1]}/_wol\"\n\n")
+
+	// _arguments leaves the options given so far in `opt_args`.
+	fmt.Fprintf(w, "_wol_aliases() {\n")
+	fmt.Fprintf(w, "    local -a aliases args\n")
+	fmt.Fprintf(w, "    local opt\n")
+	fmt.Fprintf(w, "    for opt in %s; do\n", strings.Join(storeFlags(), " "))
+	fmt.Fprintf(w, "        (( ${+opt_args[$opt]} )) && args+=( $opt \"${(Q)opt_args[$opt]}\" )\n")
+	fmt.Fprintf(w, "    done\n")
+	fmt.Fprintf(w, "    aliases=( ${(f)\"$(wol $args %s aliases 2>/dev/null)\"} )\n", completeCmdName)
+	fmt.Fprintf(w, "    _describe -t aliases 'alias' aliases\n")
+	fmt.Fprintf(w, "}\n\n")
+
+	fmt.Fprintf(w, "_wol_interfaces() {\n")
+	fmt.Fprintf(w, "    local -a interfaces\n")
+	fmt.Fprintf(w, "    interfaces=( ${(f)\"$(wol %s interfaces 2>/dev/null)\"} )\n", completeCmdName)
+	fmt.Fprintf(w, "    _describe -t interfaces 'interface' interfaces\n")
+	fmt.Fprintf(w, "}\n\n")
+
+	fmt.Fprintf(w, "_wol() {\n")
+	fmt.Fprintf(w, "    local -a commands\n")
+	fmt.Fprintf(w, "    local state\n")
+	fmt.Fprintf(w, "    commands=(\n")
+	for _, c := range validCommands {
+		fmt.Fprintf(w, "        '%s:%s'\n", c.name, zshEscape(c.description))
+	}
+	fmt.Fprintf(w, "    )\n\n")
+
+	fmt.Fprintf(w, "    _arguments -s \\\n")
+	for _, o := range completionOptions() {
+		spec := fmt.Sprintf("'(%s)'{%s}'[%s]", strings.Join(o.flags(), " "), strings.Join(o.flags(), ","), zshEscape(o.description))
+		if len(o.short) == 0 {
+			spec = fmt.Sprintf("'--%s[%s]", o.long, zshEscape(o.description))
+		}
+		if o.takesValue {
+			switch {
+			case interfaceOptions[o.long]:
+				spec += ":interface:_wol_interfaces"
+			case len(optionCompletions[o.long]) > 0:
+				spec += fmt.Sprintf(":%s:(%s)", o.long, strings.Join(optionCompletions[o.long], " "))
+			case fileOptions[o.long]:
+				spec += ":file:_files"
+			case dirOptions[o.long]:
+				spec += ":directory:_files -/"
+			default:
+				spec += fmt.Sprintf(":%s: ", o.long)
+			}
+		}
+		fmt.Fprintf(w, "        %s' \\\n", spec)
+	}
+	fmt.Fprintf(w, "        '1: :->command' \\\n")
+	fmt.Fprintf(w, "        '*:: :->args'\n\n")
+
+	fmt.Fprintf(w, "    case $state in\n")
+	fmt.Fprintf(w, "        command)\n")
+	fmt.Fprintf(w, "            _describe -t commands 'command' commands\n")
+	fmt.Fprintf(w, "            _wol_aliases ;;\n")
+	fmt.Fprintf(w, "        args)\n")
+	fmt.Fprintf(w, "            case $words[1] in\n")
+	fmt.Fprintf(w, "                %s) _wol_aliases ;;\n", strings.Join(aliasCommands, "|"))
+	fmt.Fprintf(w, "                %s) _files ;;\n", strings.Join(fileCommands, "|"))
+	for _, cmd := range sortedKeys(commandArgs) {
+		fmt.Fprintf(w, "                %s) compadd %s ;;\n", cmd, strings.Join(commandArgs[cmd], " "))
+	}
+	fmt.Fprintf(w, "            esac ;;\n")
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "_wol \"$@\"\n")
+}
+
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintf(w, "# fish completion for wol\n")
+	fmt.Fprintf(w, "# Load with: wol completion fish > ~/.config/fish/completions/wol.fish\n\n")
+	fmt.Fprintf(w, "complete -c wol -f\n\n")
+
+	// List the aliases of the store picked on the command line.
+	var longs []string
+	for _, f := range storeFlags() {
+		if strings.HasPrefix(f, "--") {
+			longs = append(longs, "'"+f+"=*'")
+		}
+	}
+	fmt.Fprintf(w, "function __wol_aliases\n")
+	fmt.Fprintf(w, "    set -l tokens (commandline -opc)\n")
+	fmt.Fprintf(w, "    set -l args\n")
+	fmt.Fprintf(w, "    set -l i 2\n")
+	fmt.Fprintf(w, "    while test $i -le (count $tokens)\n")
+	fmt.Fprintf(w, "        switch $tokens[$i]\n")
+	fmt.Fprintf(w, "            case %s\n", strings.Join(storeFlags(), " "))
+	fmt.Fprintf(w, "                if test $i -lt (count $tokens)\n")
+	fmt.Fprintf(w, "                    set args $args $tokens[$i] $tokens[(math $i + 1)]\n")
+	fmt.Fprintf(w, "                end\n")
+	fmt.Fprintf(w, "                set i (math $i + 1)\n")
+	fmt.Fprintf(w, "            case %s\n", strings.Join(longs, " "))
+	fmt.Fprintf(w, "                set args $args $tokens[$i]\n")
+	fmt.Fprintf(w, "        end\n")
+	fmt.Fprintf(w, "        set i (math $i + 1)\n")
+	fmt.Fprintf(w, "    end\n")
+	fmt.Fprintf(w, "    wol $args %s aliases 2>/dev/null\n", completeCmdName)
+	fmt.Fprintf(w, "end\n\n")
+
+	for _, c := range validCommands {
+		fmt.Fprintf(w, "complete -c wol -n __fish_use_subcommand -a %s -d '%s'\n", c.name, fishEscape(c.description))
+	}
+	fmt.Fprintf(w, "complete -c wol -n __fish_use_subcommand -a '(__wol_aliases)' -d alias\n\n")
+
+	for _, o := range completionOptions() {
+		line := fmt.Sprintf("complete -c wol -s %s -l %s -d '%s'", o.short, o.long, fishEscape(o.description))
+		if len(o.short) == 0 {
+			line = fmt.Sprintf("complete -c wol -l %s -d '%s'", o.long, fishEscape(o.description))
+		}
+		if o.takesValue {
+			switch {
+			case interfaceOptions[o.long]:
+				line += fmt.Sprintf(" -x -a '(wol %s interfaces 2>/dev/null)'", completeCmdName)
+			case len(optionCompletions[o.long]) > 0:
+				line += fmt.Sprintf(" -x -a '%s'", strings.Join(optionCompletions[o.long], " "))
+			case fileOptions[o.long], dirOptions[o.long]:
+				line += " -r -F"
+			default:
+				line += " -x"
+			}
+		}
+		fmt.Fprintf(w, "%s\n", line)
+	}
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "complete -c wol -n '__fish_seen_subcommand_from %s' -a '(__wol_aliases)' -d alias\n", strings.Join(aliasCommands, " "))
+	fmt.Fprintf(w, "complete -c wol -n '__fish_seen_subcommand_from %s' -F\n", strings.Join(fileCommands, " "))
+	for _, cmd := range sortedKeys(commandArgs) {
+		fmt.Fprintf(w, "complete -c wol -n '__fish_seen_subcommand_from %s' -a '%s'\n", cmd, strings.Join(commandArgs[cmd], " "))
+	}
+}
+
+// zshEscape escapes characters with a special meaning inside a zsh
+// `_arguments` spec or `_describe` entry.
+func zshEscape(s string) string {
+	return strings.NewReplacer(`'`, `'\''`, `:`, `\:`, `[`, `\[`, `]`, `\]`).Replace(s)
+}
+
+// fishEscape escapes a string for use inside single quotes in fish.
+func fishEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
+
+// sortedKeys returns the keys of `mp` in sorted order.
+func sortedKeys(mp map[string][]string) []string {
+	keys := make([]string, 0, len(mp))
+	for k := range mp {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Run the completion command.
+func completionCmd(args []string, store aliases.Store) error {
+	if len(args) < 1 {
+		return usageError("completion command requires a <shell> (bash, zsh or fish)")
+	}
+	return writeCompletion(os.Stdout, args[0])
+}
+
+// Run the hidden command used by the completion scripts. It prints one name
+// per line for the kind of value requested.
+func completeCmd(args []string, store aliases.Store) error {
+	if len(args) < 1 {
+		return usageError("nothing to complete")
+	}
+	return writeCompletionValues(os.Stdout, args[0], store)
+}
+
+// writeCompletionValues writes the alias or interface names to `w`.
+func writeCompletionValues(w io.Writer, kind string, store aliases.Store) error {
+	switch kind {
+	case "aliases":
+		return store.ForEach(func(alias string, mi aliases.MacIface) error {
+			_, err := fmt.Fprintln(w, alias)
+			return err
+		})
+	case "interfaces":
+		ifaces, err := net.Interfaces()
+		if err != nil {
+			return err
+		}
+		for _, iface := range ifaces {
+			fmt.Fprintln(w, iface.Name)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown completion %q", kind)
+}
@@ -0,0 +1,102 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// The completion scripts are generated from the usage tables, so make sure
+// those agree with the options the parser actually understands.
+func TestValidOptionsMatchParser(t *testing.T) {
+	shorts := map[string]string{}
+	for _, o := range allOptions(newParser().Command.Group) {
+		shorts[o.LongName] = ""
+		if o.ShortName != 0 {
+			shorts[o.LongName] = string(o.ShortName)
+		}
+	}
+	for _, o := range validOptions {
+		short, ok := shorts[o.long]
+		assert.True(t, ok, o.long)
+		assert.Equal(t, o.short, short, o.long)
+	}
+}
+
+func TestWriteCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var buf bytes.Buffer
+		assert.Nil(t, writeCompletion(&buf, shell))
+		script := buf.String()
+
+		for _, c := range validCommands {
+			assert.True(t, strings.Contains(script, c.name), shell+": "+c.name)
+		}
+		for _, o := range validOptions {
+			assert.True(t, strings.Contains(script, o.long), shell+": "+o.long)
+		}
+		assert.True(t, strings.Contains(script, completeCmdName+" aliases"), shell)
+		assert.True(t, strings.Contains(script, completeCmdName+" interfaces"), shell)
+
+		// Syntax check the script if the shell is around.
+		if path, err := exec.LookPath(shell); err == nil {
+			cmd := exec.Command(path, "-n")
+			cmd.Stdin = strings.NewReader(script)
+			out, err := cmd.CombinedOutput()
+			assert.Nil(t, err, shell+": "+string(out))
+		}
+	}
+
+	assert.NotNil(t, writeCompletion(&bytes.Buffer{}, "powershell"))
+}
+
+// Validates that the bash script lists the aliases of the store picked on the
+// command line being completed.
+func TestBashCompletionStoreFlags(t *testing.T) {
+	path, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, writeCompletion(&buf, "bash"))
+	for _, tc := range []struct {
+		words, expected string
+	}{
+		{`wol wake ""`, "__complete aliases"},
+		{`wol -d /x -o json wake ""`, "-d /x __complete aliases"},
+		{`wol --db-dir = /x --store = json -P lab ""`, "--db-dir /x --store json -P lab __complete aliases"},
+		{`wol -s json --config /c.toml rename ""`, "-s json --config /c.toml __complete aliases"},
+	} {
+		// `wol` echoes its args rather than listing any aliases.
+		script := buf.String() + "\nwol() { echo \"$@\"; }\n" +
+			"COMP_WORDS=(" + tc.words + "); COMP_CWORD=$(( ${#COMP_WORDS[@]} - 1 ))\n" +
+			"_wol_aliases\n"
+		out, err := exec.Command(path, "-c", script).CombinedOutput()
+		assert.Nil(t, err, string(out))
+		assert.Equal(t, tc.expected, strings.TrimSpace(string(out)), tc.words)
+	}
+}
+
+func TestWriteCompletionValues(t *testing.T) {
+	store := aliases.NewMemStore()
+	assert.Nil(t, store.Add("skynet", "00:11:22:33:44:55", ""))
+	assert.Nil(t, store.Add("desktop", "00:11:22:33:44:66", ""))
+
+	var buf bytes.Buffer
+	assert.Nil(t, writeCompletionValues(&buf, "aliases", store))
+	assert.Equal(t, "desktop\nskynet\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, writeCompletionValues(&buf, "interfaces", store))
+
+	assert.NotNil(t, writeCompletionValues(&buf, "groups", store))
+}
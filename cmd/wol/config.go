@@ -0,0 +1,454 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	flags "github.com/jessevdk/go-flags"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	defaultConfigName = "config.toml"
+	envPrefix         = "WOL_"
+)
+
+var (
+	// Options which only make sense on the command line and therefore can not
+	// be given a default from the environment or the config file.
+	unconfigurable = map[string]bool{
+		"version": true,
+		"help":    true,
+		"config":  true,
+		"mac":     true,
+		"iface":   true,
+		"host":    true,
+		"pcap":    true,
+	}
+
+	// agentKeys are the settings of an `[agents.<name>]` section.
+	agentKeys = map[string]bool{
+		"url":     true,
+		"token":   true,
+		"aliases": true,
+	}
+
+	// webhookKeys are the settings of a `[webhooks.<name>]` section.
+	webhookKeys = map[string]bool{
+		"url":     true,
+		"secret":  true,
+		"events":  true,
+		"aliases": true,
+	}
+
+	// secretOptions are not shown by `wol config show`.
+	secretOptions = map[string]bool{
+		"token": true,
+	}
+
+	// explicitFlags records which options were given on the command line, as
+	// opposed to picked up from a lower layer.
+	explicitFlags = map[string]bool{}
+
+	// effectiveConfig is populated once all the configuration layers have
+	// been applied, so that `wol config show` can report on it.
+	effectiveConfig configReport
+)
+
+// configEntry describes the value of a single option along with the layer
+// it was picked up from ("flag", "env", "config" or "default").
+type configEntry struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Config holds the contents of a config file. Keys are the long names of the
+// cli options (without the leading dashes). Values in the `Global` section
+// apply to every command, `Commands` holds per-command overrides and
+// `Profiles` holds named sets of settings which can be selected with
+// `--profile`. `Agents` lists the remote agents to wake aliases through and
+// `Webhooks` the URLs to notify of wakes.
+//
+//	profile = "lab"
+//	port    = 7
+//
+//	[commands.wake]
+//	interface = "enp3s0"
+//
+//	[profiles.lab]
+//	bcast = "192.168.1.255"
+//
+//	[agents.lab]
+//	url     = "http://lab-pi:8420"
+//	token   = "s3cret"
+//	aliases = "nas, build1"
+//
+//	[webhooks.slack]
+//	url     = "https://hooks.slack.com/services/..."
+//	secret  = "s3cret"
+type Config struct {
+	Profile  string
+	Global   map[string]string
+	Commands map[string]map[string]string
+	Profiles map[string]map[string]string
+	Agents   map[string]map[string]string
+	Webhooks map[string]map[string]string
+}
+
+// LoadConfig reads the config file at `path`.
+func LoadConfig(path string) (*Config, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	cfg, err := parseConfig(fp)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// parseConfig reads the (small) subset of TOML that we support: comments,
+// `[section]` headers and `key = value` pairs where the value is either a
+// quoted string, a number or a boolean.
+func parseConfig(r io.Reader) (*Config, error) {
+	cfg := &Config{
+		Global:   map[string]string{},
+		Commands: map[string]map[string]string{},
+		Profiles: map[string]map[string]string{},
+		Agents:   map[string]map[string]string{},
+		Webhooks: map[string]map[string]string{},
+	}
+
+	// Sections with `keys` hold their own settings rather than options.
+	section, global := cfg.Global, true
+	var keys map[string]bool
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		// Section headers are of the form `[commands.<cmd>]`,
+		// `[profiles.<name>]`, `[agents.<name>]` or `[webhooks.<name>]`.
+		if line[0] == '[' {
+			header := strings.TrimSpace(stripComment(line))
+			if !strings.HasSuffix(header, "]") {
+				return nil, fmt.Errorf("line %d: malformed section %q", lineNo, line)
+			}
+			parts := strings.SplitN(strings.Trim(header, "[]"), ".", 2)
+			if len(parts) != 2 || len(parts[1]) == 0 {
+				return nil, fmt.Errorf("line %d: unknown section %q", lineNo, header)
+			}
+
+			var tbl map[string]map[string]string
+			keys = nil
+			switch parts[0] {
+			case "commands":
+				tbl = cfg.Commands
+			case "profiles":
+				tbl = cfg.Profiles
+			case "agents":
+				tbl, keys = cfg.Agents, agentKeys
+			case "webhooks":
+				tbl, keys = cfg.Webhooks, webhookKeys
+			default:
+				return nil, fmt.Errorf("line %d: unknown section %q", lineNo, header)
+			}
+			name := strings.Trim(parts[1], `"`)
+			if _, ok := tbl[name]; !ok {
+				tbl[name] = map[string]string{}
+			}
+			section, global = tbl[name], false
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key := strings.TrimSpace(kv[0])
+		value, err := parseConfigValue(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+
+		switch {
+		case key == "profile" && global:
+			cfg.Profile = value
+		case keys != nil:
+			if !keys[key] {
+				return nil, fmt.Errorf("line %d: unknown setting %q", lineNo, key)
+			}
+			section[key] = value
+		case !isConfigurable(key):
+			return nil, fmt.Errorf("line %d: unknown option %q", lineNo, key)
+		default:
+			section[key] = value
+		}
+	}
+	return cfg, scanner.Err()
+}
+
+// parseConfigValue unquotes a string value, or returns bare numbers and
+// booleans as is.
+func parseConfigValue(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, `'`) {
+		quote := s[:1]
+		end := strings.Index(s[1:], quote)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		// Only a comment may follow the closing quote.
+		if rest := strings.TrimSpace(s[end+2:]); len(rest) > 0 && rest[0] != '#' {
+			return "", fmt.Errorf("unexpected %q after string", rest)
+		}
+		if quote == `'` {
+			return s[1 : end+1], nil
+		}
+		return strconv.Unquote(s[:end+2])
+	}
+	return strings.TrimSpace(stripComment(s)), nil
+}
+
+// stripComment removes a trailing `# comment` from an unquoted value.
+func stripComment(s string) string {
+	if idx := strings.Index(s, "#"); idx >= 0 {
+		return s[:idx]
+	}
+	return s
+}
+
+// Values returns the settings for `cmd` with the `profile` applied. Command
+// settings override the global ones, and the profile overrides both since it
+// was explicitly asked for.
+func (c *Config) Values(cmd, profile string) (map[string]string, error) {
+	values := map[string]string{}
+	for k, v := range c.Global {
+		values[k] = v
+	}
+	for k, v := range c.Commands[cmd] {
+		values[k] = v
+	}
+	if len(profile) > 0 {
+		p, ok := c.Profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("profile %q not found in config", profile)
+		}
+		for k, v := range p {
+			values[k] = v
+		}
+	}
+	return values, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// isConfigurable reports whether `name` is the long name of an option which
+// can be given a default through the environment or config file. The names
+// come from the tags of cliFlags so that they can not drift from the parser.
+func isConfigurable(name string) bool {
+	if unconfigurable[name] {
+		return false
+	}
+	t := reflect.TypeOf(cliFlags)
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("long") == name {
+			return true
+		}
+	}
+	return false
+}
+
+// optionSource returns the layer the option `name` was picked up from, as in
+// configEntry.
+func optionSource(name string) string {
+	for _, e := range effectiveConfig.Entries {
+		if e.Name == name {
+			return e.Source
+		}
+	}
+	if explicitFlags[name] {
+		return "flag"
+	}
+	return "default"
+}
+
+// envKey returns the environment variable which overrides the option `name`,
+// for example `WOL_DB_DIR` for `db-dir`.
+func envKey(name string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// optionArgs converts an option and a value into the equivalent cli args.
+func optionArgs(o *flags.Option, value string) ([]string, error) {
+	if _, ok := o.Value().(bool); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean %q for %s", value, o.LongName)
+		}
+		if b {
+			return []string{"--" + o.LongName}, nil
+		}
+		return nil, nil
+	}
+	return []string{"--" + o.LongName + "=" + value}, nil
+}
+
+// allOptions returns the options defined in `g` and all of its sub-groups.
+func allOptions(g *flags.Group) []*flags.Option {
+	opts := g.Options()
+	for _, sub := range g.Groups() {
+		opts = append(opts, allOptions(sub)...)
+	}
+	return opts
+}
+
+// defaultConfigPath returns the location of the per-user config file.
+func defaultConfigPath(home string) string {
+	return filepath.Join(configDir(home), defaultConfigName)
+}
+
+// commandName returns the command which will be run for `args`.
+func commandName(args []string) string {
+	if len(args) > 0 {
+		cmd := strings.ToLower(args[0])
+		if _, ok := cmdMap[cmd]; ok {
+			return cmd
+		}
+	}
+	return "wake"
+}
+
+// parseWithoutDefaults parses `args` with the built-in defaults disabled, so
+// that IsSet() only reports the options which were given explicitly.
+func parseWithoutDefaults(parser *flags.Parser, args []string) ([]string, error) {
+	defaults := map[*flags.Option][]string{}
+	for _, o := range allOptions(parser.Command.Group) {
+		defaults[o], o.Default = o.Default, nil
+	}
+	defer func() {
+		for o, d := range defaults {
+			o.Default = d
+		}
+	}()
+	return parser.ParseArgs(args)
+}
+
+// applyConfig layers the environment and config file underneath the options
+// parsed from the command line by parseWithoutDefaults. The precedence, from
+// highest to lowest, is: flags, environment variables, config file, built-in
+// defaults. The command line `rawArgs` are re-parsed on top of the lower
+// layers and the resulting positional args are returned.
+func applyConfig(parser *flags.Parser, rawArgs, args []string, home string) ([]string, error) {
+	cliSet := map[string]bool{}
+	for _, o := range allOptions(parser.Command.Group) {
+		cliSet[o.LongName] = o.IsSet()
+	}
+	explicitFlags = cliSet
+
+	// Figure out which config file to read. A missing file is only an error
+	// if the user explicitly asked for it.
+	path, required := cliFlags.Config, true
+	if len(path) == 0 {
+		path = os.Getenv(envKey("config"))
+	}
+	if len(path) == 0 {
+		path, required = defaultConfigPath(home), false
+	}
+
+	cfg, err := LoadConfig(path)
+	switch {
+	case err == nil:
+		effectiveConfig.Found = true
+	case os.IsNotExist(err) && !required:
+		cfg, err = &Config{}, nil
+	default:
+		return nil, err
+	}
+	effectiveConfig.Path = path
+
+	profile := cliFlags.Profile
+	if len(profile) == 0 {
+		profile = os.Getenv(envKey("profile"))
+	}
+	if len(profile) == 0 {
+		profile = cfg.Profile
+	}
+	effectiveConfig.Profile = profile
+	configAgents, configWebhooks = cfg.Agents, cfg.Webhooks
+
+	values, err := cfg.Values(commandName(args), profile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build up the args for the lower layers. These get prepended to the
+	// command line so that anything given explicitly wins.
+	var pre []string
+	sources := map[string]string{}
+	for _, o := range allOptions(parser.Command.Group) {
+		name := o.LongName
+		if unconfigurable[name] {
+			continue
+		}
+
+		var extra []string
+		envValue, inEnv := os.LookupEnv(envKey(name))
+		cfgValue, inCfg := values[name]
+		switch {
+		case cliSet[name]:
+			sources[name] = "flag"
+		case inEnv:
+			sources[name] = "env"
+			extra, err = optionArgs(o, envValue)
+		case inCfg:
+			sources[name] = "config"
+			extra, err = optionArgs(o, cfgValue)
+		default:
+			sources[name] = "default"
+		}
+		if err != nil {
+			return nil, err
+		}
+		pre = append(pre, extra...)
+	}
+
+	args, err = parser.ParseArgs(append(pre, rawArgs...))
+	if err != nil {
+		return nil, err
+	}
+
+	// The profile is reported separately since it can be picked up from the
+	// top of the config file.
+	effectiveConfig.Entries = nil
+	for _, o := range allOptions(parser.Command.Group) {
+		if src, ok := sources[o.LongName]; ok && o.LongName != "profile" {
+			value := fmt.Sprint(o.Value())
+			if secretOptions[o.LongName] && len(value) > 0 {
+				value = "********"
+			}
+			effectiveConfig.Entries = append(effectiveConfig.Entries, configEntry{
+				Name:   o.LongName,
+				Value:  value,
+				Source: src,
+			})
+		}
+	}
+	return args, nil
+}
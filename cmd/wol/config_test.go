@@ -0,0 +1,176 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	flags "github.com/jessevdk/go-flags"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+const testConfig = `
+# Defaults for every command.
+profile = "lab"
+port    = 7
+store   = 'json'
+
+[commands.wake]
+interface = "eth1" # trailing comment
+
+[commands.list]
+port = 8
+
+[profiles.lab]
+bcast = "192.168.1.255"
+
+[profiles.office]
+bcast = "10.0.0.255"
+port  = 9
+
+[agents.lab]
+url     = "http://lab-pi:8420"
+aliases = "nas, build1"
+
+[webhooks.slack]
+url    = "https://hooks.example.com/wol"
+events = "wake"
+`
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader(testConfig))
+	assert.Nil(t, err)
+	assert.Equal(t, "lab", cfg.Profile)
+	assert.Equal(t, map[string]string{"port": "7", "store": "json"}, cfg.Global)
+	assert.Equal(t, "eth1", cfg.Commands["wake"]["interface"])
+	assert.Equal(t, "10.0.0.255", cfg.Profiles["office"]["bcast"])
+	assert.Equal(t, "nas, build1", cfg.Agents["lab"]["aliases"])
+	assert.Equal(t, "wake", cfg.Webhooks["slack"]["events"])
+
+	for _, tc := range []struct {
+		cmd, profile string
+		expected     map[string]string
+	}{
+		{"list", "", map[string]string{"port": "8", "store": "json"}},
+		{"wake", "lab", map[string]string{"port": "7", "store": "json", "interface": "eth1", "bcast": "192.168.1.255"}},
+		{"list", "office", map[string]string{"port": "9", "store": "json", "bcast": "10.0.0.255"}},
+	} {
+		values, err := cfg.Values(tc.cmd, tc.profile)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.expected, values)
+	}
+
+	_, err = cfg.Values("wake", "missing")
+	assert.NotNil(t, err)
+}
+
+func TestParseConfigNegative(t *testing.T) {
+	for _, tc := range []string{
+		`bogus = 1`,
+		`version = true`,
+		`port`,
+		`bcast = "unterminated`,
+		`interface = "eth0" junk`,
+		`interface = 'eth0' "eth1"`,
+		`[unknown.section]`,
+		`[profiles]`,
+		`[profiles.lab`,
+		"[agents.lab]\nport = 7",
+		"[webhooks.slack]\ntoken = 'x'",
+	} {
+		_, err := parseConfig(strings.NewReader(tc))
+		assert.NotNil(t, err, tc)
+	}
+
+	_, err := parseConfig(strings.NewReader("port = 7\ninterface = \"eth0\" junk"))
+	assert.Equal(t, `line 2: unexpected "junk" after string`, err.Error())
+}
+
+// Every long option of cliFlags can be configured, except for the ones which
+// only make sense on the command line.
+func TestIsConfigurable(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"port":        true,
+		"db-dir":      true,
+		"mqtt-broker": true,
+		"watch":       true,
+		"version":     false,
+		"pcap":        false,
+		"bogus":       false,
+	} {
+		assert.Equal(t, expected, isConfigurable(name), name)
+	}
+}
+
+func TestEnvKey(t *testing.T) {
+	assert.Equal(t, "WOL_PORT", envKey("port"))
+	assert.Equal(t, "WOL_DB_DIR", envKey("db-dir"))
+	assert.Equal(t, "WOL_NO_COLOR", envKey("no-color"))
+}
+
+// Validates the flags > env > config > default precedence.
+func TestApplyConfig(t *testing.T) {
+	saved := cliFlags
+	defer func() { cliFlags = saved }()
+
+	dir, err := ioutil.TempDir("", "wol-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.toml")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(testConfig), 0660))
+
+	os.Setenv("WOL_PORT", "99")
+	defer os.Unsetenv("WOL_PORT")
+
+	rawArgs := []string{"-f", path, "-b", "1.2.3.4", "wake", "foo"}
+	parser := flags.NewParser(&cliFlags, flags.Default & ^flags.HelpFlag)
+	args, err := parseWithoutDefaults(parser, rawArgs)
+	assert.Nil(t, err)
+	args, err = applyConfig(parser, rawArgs, args, dir)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"wake", "foo"}, args)
+	assert.Equal(t, "1.2.3.4", cliFlags.BroadcastIP)
+	assert.Equal(t, "99", cliFlags.UDPPort)
+	assert.Equal(t, "eth1", cliFlags.BroadcastInterface)
+	assert.Equal(t, "json", cliFlags.Store)
+	assert.Equal(t, "", cliFlags.DBDir)
+	assert.Equal(t, "lab", effectiveConfig.Profile)
+
+	sources := map[string]string{}
+	for _, e := range effectiveConfig.Entries {
+		sources[e.Name] = e.Source
+	}
+	assert.Equal(t, "flag", sources["bcast"])
+	assert.Equal(t, "env", sources["port"])
+	assert.Equal(t, "config", sources["interface"])
+	assert.Equal(t, "default", sources["db-dir"])
+}
+
+func TestApplyConfigMissingFile(t *testing.T) {
+	saved := cliFlags
+	defer func() { cliFlags = saved }()
+
+	// A missing default config file is fine, an explicit one is not.
+	for _, tc := range []struct {
+		rawArgs []string
+		ok      bool
+	}{
+		{[]string{"list"}, true},
+		{[]string{"-f", "/does/not/exist.toml", "list"}, false},
+	} {
+		parser := flags.NewParser(&cliFlags, flags.Default & ^flags.HelpFlag)
+		args, err := parseWithoutDefaults(parser, tc.rawArgs)
+		assert.Nil(t, err)
+		_, err = applyConfig(parser, tc.rawArgs, args, "/does/not/exist")
+		assert.Equal(t, tc.ok, err == nil)
+	}
+}
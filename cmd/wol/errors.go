@@ -0,0 +1,80 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/sabhiram/go-wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+var (
+	// ErrNoInterfaceAddress is returned when the outbound interface has no
+	// address we can send from.
+	ErrNoInterfaceAddress = errors.New("no address associated with interface")
+
+	// ErrUsage is matched by the errors about missing or invalid arguments
+	// of a command.
+	ErrUsage = errors.New("invalid usage")
+)
+
+// usageError is an error in the arguments given to a command, it matches
+// ErrUsage.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// Unwrap allows errors.Is to match ErrUsage.
+func (e usageError) Unwrap() error {
+	return ErrUsage
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Exit codes returned by the wol binary. These are part of the documented
+// interface so existing values must never change.
+const (
+	exitOK                 = 0
+	exitFailure            = 1
+	exitUsage              = 2
+	exitInvalidMAC         = 3
+	exitUnsupportedLength  = 4
+	exitAliasNotFound      = 5
+	exitAliasExists        = 6
+	exitNoInterfaceAddress = 7
+	exitShortWrite         = 8
+	exitDBLocked           = 9
+)
+
+// exitCodes maps errors to the exit code they produce, checked in order.
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{wol.ErrInvalidMAC, exitInvalidMAC},
+	{wol.ErrUnsupportedLength, exitUnsupportedLength},
+	{aliases.ErrAliasNotFound, exitAliasNotFound},
+	{aliases.ErrAliasExists, exitAliasExists},
+	{ErrNoInterfaceAddress, exitNoInterfaceAddress},
+	{wol.ErrShortWrite, exitShortWrite},
+	{aliases.ErrDBLocked, exitDBLocked},
+	{ErrUsage, exitUsage},
+}
+
+// exitCode returns the exit code to use for `err`.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	for _, ec := range exitCodes {
+		if errors.Is(err, ec.err) {
+			return ec.code
+		}
+	}
+	return exitFailure
+}
@@ -0,0 +1,77 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/sabhiram/go-wol/wol"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestExitCode(t *testing.T) {
+	_, macErr := wol.ParseMAC("foobar")
+	_, lenErr := wol.ParseMAC("01:23:45:67:89:ab:cd:ef")
+
+	for _, tc := range []struct {
+		err      error
+		expected int
+	}{
+		{nil, exitOK},
+		{errors.New("something else"), exitFailure},
+		{macErr, exitInvalidMAC},
+		{lenErr, exitUnsupportedLength},
+		{&aliases.AliasError{Alias: "foo", Err: aliases.ErrAliasNotFound}, exitAliasNotFound},
+		{&aliases.AliasError{Alias: "foo", Err: aliases.ErrAliasExists}, exitAliasExists},
+		{fmt.Errorf("%w eth0", ErrNoInterfaceAddress), exitNoInterfaceAddress},
+		{fmt.Errorf("%w: sent 3 bytes", wol.ErrShortWrite), exitShortWrite},
+		{fmt.Errorf("%w: bolt.db", aliases.ErrDBLocked), exitDBLocked},
+		{usageError("rename command requires an <old> and a <new> alias"), exitUsage},
+	} {
+		assert.Equal(t, tc.expected, exitCode(tc.err), fmt.Sprint(tc.err))
+	}
+}
+
+func TestAliasErrors(t *testing.T) {
+	store := aliases.NewMemStore()
+	assert.Nil(t, store.Add("foo", "00:11:22:33:44:55", ""))
+
+	_, _, err := resolveTarget("bar", store, false)
+	assert.True(t, errors.Is(err, aliases.ErrAliasNotFound))
+	assert.Equal(t, exitAliasNotFound, exitCode(err))
+
+	err = store.Copy("foo", "foo")
+	assert.Equal(t, exitAliasExists, exitCode(err))
+
+	_, err = ipFromInterface("fake-interface-0")
+	assert.Equal(t, exitFailure, exitCode(err))
+}
+
+func TestUsageErrors(t *testing.T) {
+	store := aliases.NewMemStore()
+	for _, tc := range []struct {
+		fn   func([]string, aliases.Store) error
+		args []string
+	}{
+		{aliasCmd, []string{"foo"}},
+		{removeCmd, nil},
+		{renameCmd, []string{"foo"}},
+		{copyCmd, []string{"foo"}},
+		{editCmd, nil},
+		{editCmd, []string{"foo"}},
+		{inspectCmd, nil},
+		{completionCmd, nil},
+		{configCmd, []string{"frobnicate"}},
+	} {
+		err := tc.fn(tc.args, store)
+		assert.Equal(t, exitUsage, exitCode(err), fmt.Sprint(err))
+	}
+
+	_, _, err := validateMAC("ff:ff:ff:ff:ff:ff")
+	assert.Equal(t, exitInvalidMAC, exitCode(err))
+}
@@ -0,0 +1,164 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ifaceAddr is an address of a local interface which can be used to send
+// from. Interfaces without any addresses are listed with a nil `IPNet`.
+type ifaceAddr struct {
+	Name         string
+	HardwareAddr net.HardwareAddr
+	IPNet        *net.IPNet
+}
+
+func (a ifaceAddr) String() string {
+	if a.IPNet == nil {
+		return a.Name + " (no address)"
+	}
+	return a.Name + " " + a.IPNet.String()
+}
+
+// interfaceAddrs lists the addresses of all of the local interfaces.
+func interfaceAddrs() ([]ifaceAddr, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []ifaceAddr
+	for _, ief := range interfaces {
+		addrs, err := ief.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				out = append(out, ifaceAddr{ief.Name, ief.HardwareAddr, ipnet})
+				found = true
+			}
+		}
+		if !found {
+			out = append(out, ifaceAddr{ief.Name, ief.HardwareAddr, nil})
+		}
+	}
+	return out, nil
+}
+
+// resolveInterface picks the local address to send from for the `--interface`
+// value `spec`, see selectInterface.
+func resolveInterface(spec string) (ifaceAddr, error) {
+	candidates, err := interfaceAddrs()
+	if err != nil {
+		return ifaceAddr{}, err
+	}
+	return selectInterface(spec, candidates)
+}
+
+// selectInterface picks the address to send from out of `candidates`. The
+// `spec` can be:
+//
+//	an interface name   the first non-loopback IPv4 address of the interface
+//	an IP address       that exact address, or else the address whose subnet
+//	                    contains it
+//	a CIDR              the address which lies within it
+//
+// Only IPv4 addresses are picked since the magic packet is broadcast over
+// IPv4. Errors list the candidates to choose from instead.
+func selectInterface(spec string, candidates []ifaceAddr) (ifaceAddr, error) {
+	if ip, ipnet, err := net.ParseCIDR(spec); err == nil {
+		if ip.To4() == nil {
+			return ifaceAddr{}, errNotIPv4(spec, candidates)
+		}
+		return pickAddr(spec, candidates, func(a ifaceAddr) bool {
+			return ipnet.Contains(a.IPNet.IP) || a.IPNet.Contains(ip)
+		})
+	}
+
+	if ip := net.ParseIP(spec); ip != nil {
+		if ip.To4() == nil {
+			return ifaceAddr{}, errNotIPv4(spec, candidates)
+		}
+		for _, a := range candidates {
+			if isIPv4Addr(a) && a.IPNet.IP.Equal(ip) {
+				return a, nil
+			}
+		}
+		return pickAddr(spec, candidates, func(a ifaceAddr) bool {
+			return a.IPNet.Contains(ip)
+		})
+	}
+
+	known := false
+	for _, a := range candidates {
+		if a.Name != spec {
+			continue
+		}
+		known = true
+		if isIPv4Addr(a) && !a.IPNet.IP.IsLoopback() {
+			return a, nil
+		}
+	}
+	if !known {
+		return ifaceAddr{}, fmt.Errorf("no such network interface %s (%s)",
+			spec, candidateList(candidates))
+	}
+	return ifaceAddr{}, fmt.Errorf("%w %s: no IPv4 address (%s)",
+		ErrNoInterfaceAddress, spec, candidateList(candidates))
+}
+
+// pickAddr returns the first address for which `match` is true. The matches
+// must all be on the same interface, otherwise it is up to the user to pick
+// one.
+func pickAddr(spec string, candidates []ifaceAddr, match func(ifaceAddr) bool) (ifaceAddr, error) {
+	var matches []ifaceAddr
+	for _, a := range candidates {
+		if isIPv4Addr(a) && match(a) {
+			matches = append(matches, a)
+		}
+	}
+
+	switch {
+	case len(matches) == 0:
+		return ifaceAddr{}, fmt.Errorf("%w %s (%s)",
+			ErrNoInterfaceAddress, spec, candidateList(candidates))
+	case len(matches) > 1:
+		for _, a := range matches[1:] {
+			if a.Name != matches[0].Name {
+				return ifaceAddr{}, fmt.Errorf("%s matches several interfaces, pick one of: %s",
+					spec, candidateList(matches))
+			}
+		}
+	}
+	return matches[0], nil
+}
+
+// isIPv4Addr reports whether `a` has an IPv4 address to send from.
+func isIPv4Addr(a ifaceAddr) bool {
+	return a.IPNet != nil && a.IPNet.IP.To4() != nil
+}
+
+// errNotIPv4 is returned for an IPv6 `spec`, which can never be sent from.
+func errNotIPv4(spec string, candidates []ifaceAddr) error {
+	return fmt.Errorf("%w %s: no IPv4 address, magic packets are broadcast over IPv4 (%s)",
+		ErrNoInterfaceAddress, spec, candidateList(candidates))
+}
+
+// candidateList formats `candidates` for an error message.
+func candidateList(candidates []ifaceAddr) string {
+	if len(candidates) == 0 {
+		return "no interfaces found"
+	}
+	names := make([]string, 0, len(candidates))
+	for _, a := range candidates {
+		names = append(names, a.String())
+	}
+	return "available: " + strings.Join(names, ", ")
+}
@@ -0,0 +1,87 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// testIfaceAddr builds a candidate address from a CIDR.
+func testIfaceAddr(name, cidr string) ifaceAddr {
+	if len(cidr) == 0 {
+		return ifaceAddr{Name: name}
+	}
+	ip, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	ipnet.IP = ip
+	return ifaceAddr{Name: name, IPNet: ipnet}
+}
+
+func TestSelectInterface(t *testing.T) {
+	candidates := []ifaceAddr{
+		testIfaceAddr("lo", "127.0.0.1/8"),
+		testIfaceAddr("eth0", "fe80::1/64"),
+		testIfaceAddr("eth0", "192.168.1.5/24"),
+		testIfaceAddr("eth0", "192.168.1.6/24"),
+		testIfaceAddr("eth1", "10.0.0.2/16"),
+		testIfaceAddr("wlan0", "10.1.0.2/16"),
+		testIfaceAddr("down0", ""),
+		testIfaceAddr("v6only", "2001:db8::2/64"),
+	}
+
+	for _, tc := range []struct {
+		spec, name, ip string
+	}{
+		// Names pick the first non-loopback IPv4 address.
+		{"eth0", "eth0", "192.168.1.5"},
+		{"eth1", "eth1", "10.0.0.2"},
+
+		// IP addresses pick that exact address, or the subnet containing it.
+		{"192.168.1.6", "eth0", "192.168.1.6"},
+		{"192.168.1.200", "eth0", "192.168.1.5"},
+		{"10.1.44.1", "wlan0", "10.1.0.2"},
+
+		// CIDRs pick the address within them.
+		{"192.168.1.0/24", "eth0", "192.168.1.5"},
+		{"10.0.0.0/16", "eth1", "10.0.0.2"},
+		{"10.1.0.0/24", "wlan0", "10.1.0.2"},
+	} {
+		a, err := selectInterface(tc.spec, candidates)
+		assert.Nil(t, err, tc.spec)
+		assert.Equal(t, tc.name, a.Name, tc.spec)
+		assert.Equal(t, tc.ip, a.IPNet.IP.String(), tc.spec)
+	}
+
+	for _, tc := range []struct {
+		spec, message string
+		noAddress     bool
+	}{
+		{"eth9", "no such network interface eth9", false},
+		{"down0", "down0 (no address)", true},
+		{"lo", "no address associated with interface lo: no IPv4 address", true},
+		{"v6only", "v6only: no IPv4 address", true},
+		{"172.16.0.1", "172.16.0.1", true},
+		{"172.16.0.0/12", "172.16.0.0/12", true},
+		{"10.0.0.0/8", "matches several interfaces", false},
+
+		// Only IPv4 addresses can be sent from.
+		{"fe80::1", "fe80::1: no IPv4 address", true},
+		{"fe80::/64", "fe80::/64: no IPv4 address", true},
+		{"::ffff:172.16.0.1", "::ffff:172.16.0.1", true},
+	} {
+		_, err := selectInterface(tc.spec, candidates)
+		assert.NotNil(t, err, tc.spec)
+		assert.True(t, strings.Contains(err.Error(), tc.message), err.Error())
+		assert.True(t, strings.Contains(err.Error(), "eth1 10.0.0.2/16"), err.Error())
+		assert.Equal(t, tc.noAddress, errors.Is(err, ErrNoInterfaceAddress), tc.spec)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/sabhiram/go-wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// inspectEntry describes a magic packet found in a capture.
+type inspectEntry struct {
+	Time        string `json:"time"`
+	Transport   string `json:"transport"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Target      string `json:"target"`
+	Alias       string `json:"alias,omitempty"`
+	Password    string `json:"password,omitempty"`
+	Problem     string `json:"problem,omitempty"`
+}
+
+// inspectReport is the result of the inspect command.
+type inspectReport struct {
+	File    string         `json:"file"`
+	Packets []inspectEntry `json:"packets"`
+}
+
+func (r inspectReport) plain(w io.Writer) {
+	if len(r.Packets) == 0 {
+		fmt.Fprintf(w, "No magic packets found in %s\n", r.File)
+		return
+	}
+	for _, e := range r.Packets {
+		fmt.Fprintf(w, "%s %s %s -> %s\n", e.Time, e.Transport, valueOr(e.Source, "?"), valueOr(e.Destination, "?"))
+		if len(e.Problem) > 0 {
+			fmt.Fprintf(w, "    MALFORMED: %s\n", e.Problem)
+			continue
+		}
+		target := e.Target
+		if len(e.Alias) > 0 {
+			target = fmt.Sprintf("%s (%s)", e.Target, e.Alias)
+		}
+		fmt.Fprintf(w, "    Target:    %s\n", target)
+		if len(e.Password) > 0 {
+			fmt.Fprintf(w, "    SecureOn:  %s\n", e.Password)
+		}
+	}
+}
+
+func (r inspectReport) table() ([]string, [][]string) {
+	rows := make([][]string, 0, len(r.Packets))
+	for _, e := range r.Packets {
+		rows = append(rows, []string{e.Time, e.Transport, e.Source, e.Destination,
+			e.Target, e.Alias, e.Password, e.Problem})
+	}
+	return []string{"TIME", "TRANSPORT", "SOURCE", "DESTINATION", "TARGET", "ALIAS", "PASSWORD", "PROBLEM"}, rows
+}
+
+// malformed returns the number of malformed packets in the report.
+func (r inspectReport) malformed() int {
+	count := 0
+	for _, e := range r.Packets {
+		if len(e.Problem) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// inspectCapture reads the capture in `path` and reports the magic packets in
+// it, naming the alias for each target which is in the store.
+func inspectCapture(path string, store aliases.Store) (inspectReport, error) {
+	report := inspectReport{File: path, Packets: []inspectEntry{}}
+
+	fp, err := os.Open(path)
+	if err != nil {
+		return report, err
+	}
+	defer fp.Close()
+
+	// Index the aliases by their canonical mac so that the format they were
+	// stored in does not matter. The first alias (by name) for a mac wins.
+	byMac := map[string]string{}
+	err = store.ForEachAll(func(alias string, mis []aliases.MacIface) error {
+		for _, mi := range mis {
+			if addr, err := wol.ParseMAC(mi.Mac); err == nil {
+				if _, ok := byMac[addr.String()]; !ok {
+					byMac[addr.String()] = alias
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	// Return whatever was found before a read error, a capture which was cut
+	// short is still worth looking at.
+	packets, err := wol.InspectCapture(fp)
+	for _, cp := range packets {
+		e := inspectEntry{
+			Transport:   cp.Transport,
+			Source:      cp.Source,
+			Destination: cp.Destination,
+		}
+		if !cp.Time.IsZero() {
+			e.Time = cp.Time.Format(time.RFC3339Nano)
+		}
+		if cp.Err != nil {
+			e.Problem = cp.Err.Error()
+		} else {
+			e.Target = cp.Target.String()
+			e.Alias = byMac[e.Target]
+			if len(cp.Password) > 0 {
+				e.Password = wol.FormatPassword(cp.Password)
+			}
+		}
+		report.Packets = append(report.Packets, e)
+	}
+	if err != nil {
+		err = fmt.Errorf("reading %s: %w", path, err)
+	}
+	return report, err
+}
+
+// Run the inspect command.
+func inspectCmd(args []string, store aliases.Store) error {
+	if len(args) <= 0 {
+		return usageError("No capture file specified to inspect command")
+	}
+
+	// As with wake, the plain output leaves errors to the caller while the
+	// structured outputs include whatever was found before the error.
+	report, err := inspectCapture(args[0], store)
+	if err == nil || cliFlags.Output != outputPlain {
+		if oerr := writeOutput(os.Stdout, cliFlags.Output, report); oerr != nil {
+			return oerr
+		}
+	}
+	if err == nil && cliFlags.Output == outputPlain && report.malformed() > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d magic packets are malformed\n", report.malformed(), len(report.Packets))
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/sabhiram/go-wol/wol"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestInspectCapture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wol-inspect")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	mp, err := wol.New("00:11:22:33:44:55")
+	assert.Nil(t, err)
+	good, err := mp.RawFrame(wol.MACAddress{})
+	assert.Nil(t, err)
+	bad := append([]byte{}, good...)
+	bad[40] ^= 0xFF
+
+	path := filepath.Join(dir, "capture.pcap")
+	fp, err := os.Create(path)
+	assert.Nil(t, err)
+	assert.Nil(t, wol.WritePcap(fp, time.Unix(1500000000, 0), good, bad))
+	assert.Nil(t, fp.Close())
+
+	// The alias is stored in a different format than the canonical one.
+	store := aliases.NewMemStore()
+	assert.Nil(t, store.Add("foo", "00-11-22-33-44-55", ""))
+
+	report, err := inspectCapture(path, store)
+	assert.Nil(t, err)
+	assert.Len(t, report.Packets, 2)
+	assert.Equal(t, 1, report.malformed())
+	assert.Equal(t, "2017-07-14T02:40:00Z", report.Packets[0].Time)
+	assert.Equal(t, "ethernet", report.Packets[0].Transport)
+	assert.Equal(t, "00:11:22:33:44:55", report.Packets[0].Target)
+	assert.Equal(t, "foo", report.Packets[0].Alias)
+	assert.True(t, strings.Contains(report.Packets[1].Problem, "malformed"))
+
+	var buf bytes.Buffer
+	report.plain(&buf)
+	assert.True(t, strings.Contains(buf.String(), "Target:    00:11:22:33:44:55 (foo)"))
+	assert.True(t, strings.Contains(buf.String(), "MALFORMED: "))
+
+	// Not a capture at all.
+	assert.Nil(t, ioutil.WriteFile(path, []byte("hello"), 0600))
+	_, err = inspectCapture(path, store)
+	assert.NotNil(t, err)
+
+	_, err = inspectCapture(filepath.Join(dir, "missing.pcap"), store)
+	assert.NotNil(t, err)
+}
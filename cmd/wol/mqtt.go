@@ -0,0 +1,263 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/sabhiram/go-wol/aliases"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// The mqtt command bridges a broker to the alias store: a message published to
+// `<wake-topic>/<alias>` wakes the alias, and the results are published to
+// `<state-topic>/<alias>`. A payload of `host[:port]` also publishes whether
+// the host came up to `<state-topic>/<alias>/status`. The bridge itself is
+// "online" or "offline" at `<state-topic>`.
+const (
+	mqttOnline  = "online"
+	mqttOffline = "offline"
+
+	// Host status published to `<state-topic>/<alias>/status`.
+	hostWaking = "waking"
+	hostUp     = "up"
+	hostDown   = "down"
+
+	mqttKeepAlive  = 30 * time.Second
+	mqttMaxBackoff = time.Minute
+
+	// mqttQuiesce is how long the client has to finish sending on disconnect.
+	mqttQuiesce = 250 * time.Millisecond
+)
+
+var (
+	// hostStatusTimeout is how long a woken host has to come up.
+	hostStatusTimeout = 5 * time.Minute
+
+	// mqttMinBackoff is the first wait before reconnecting, it doubles up to
+	// mqttMaxBackoff while the broker stays unreachable.
+	mqttMinBackoff = time.Second
+)
+
+// mqttWakeState is published to the state topic of an alias once it has been
+// woken.
+type mqttWakeState struct {
+	Alias   string       `json:"alias"`
+	Results []wakeResult `json:"results"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// mqttBridge wakes the aliases it is asked to over MQTT.
+type mqttBridge struct {
+	broker     string
+	wakeTopic  string
+	stateTopic string
+	clientID   string
+	open       func() (aliases.Store, error)
+
+	client mqtt.Client
+
+	// stop is closed once the bridge is shutting down.
+	stop <-chan struct{}
+
+	// handlers tracks the requests being handled, run waits for them.
+	handlers sync.WaitGroup
+}
+
+// options returns the settings of the connection to the broker. The bridge
+// is "offline" through its will when the connection is lost, and announces
+// itself and subscribes to the wake topic on every connect.
+func (b *mqttBridge) options() (*mqtt.ClientOptions, error) {
+	u, err := parseMQTTBroker(b.broker)
+	if err != nil {
+		return nil, err
+	}
+	opts := mqtt.NewClientOptions().
+		AddBroker(u.String()).
+		SetClientID(b.clientID).
+		SetCleanSession(true).
+		SetKeepAlive(mqttKeepAlive).
+		SetConnectTimeout(mqttDialTimeout).
+		SetBinaryWill(b.stateTopic, []byte(mqttOffline), 0, true).
+		SetAutoReconnect(true).
+		SetMaxReconnectInterval(mqttMaxBackoff).
+		SetCustomOpenConnectionFn(dialMQTT)
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		b.publish(b.stateTopic, []byte(mqttOnline), true)
+		err := mqttWait(c.Subscribe(b.wakeTopic+"/+", 1, func(_ mqtt.Client, m mqtt.Message) {
+			b.handlers.Add(1)
+			go func() {
+				defer b.handlers.Done()
+				b.handle(m)
+			}()
+		}))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to subscribe to %s/+: %v\n", b.wakeTopic, err)
+			return
+		}
+		fmt.Fprintf(os.Stdout, "Connected to %s, waking on %s/<alias>\n", b.broker, b.wakeTopic)
+	})
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		fmt.Fprintf(os.Stderr, "MQTT connection to %s lost: %v, reconnecting\n", b.broker, err)
+	})
+	return opts, nil
+}
+
+// run keeps the bridge connected until `stop` is closed. The first connection
+// is retried with an exponential backoff, after that the client reconnects by
+// itself. On the way out the bridge is marked offline and disconnects, which
+// keeps the broker from publishing the will.
+func (b *mqttBridge) run(stop <-chan struct{}) error {
+	b.stop = stop
+	defer b.handlers.Wait()
+
+	opts, err := b.options()
+	if err != nil {
+		return err
+	}
+	b.client = mqtt.NewClient(opts)
+
+	backoff := mqttMinBackoff
+	for {
+		err := mqttWait(b.client.Connect())
+		if err == nil {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "MQTT connection to %s failed: %v, retrying in %s\n", b.broker, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-stop:
+			return nil
+		}
+		if backoff *= 2; backoff > mqttMaxBackoff {
+			backoff = mqttMaxBackoff
+		}
+	}
+
+	<-stop
+	if err := mqttWait(b.client.Publish(b.stateTopic, 0, true, []byte(mqttOffline))); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to publish to %s: %v\n", b.stateTopic, err)
+	}
+	b.client.Disconnect(uint(mqttQuiesce / time.Millisecond))
+	return nil
+}
+
+// publish sends a QoS 0 message. Messages are dropped while reconnecting and
+// once the bridge is stopping.
+func (b *mqttBridge) publish(topic string, payload []byte, retain bool) {
+	if b.stopping() {
+		return
+	}
+	if err := mqttWait(b.client.Publish(topic, 0, retain, payload)); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to publish to %s: %v\n", topic, err)
+	}
+}
+
+// mqttWait waits for the client to be done with `t`.
+func mqttWait(t mqtt.Token) error {
+	if !t.WaitTimeout(mqttDialTimeout) {
+		return errors.New("mqtt: timed out")
+	}
+	return t.Error()
+}
+
+// stopping reports whether the bridge is shutting down.
+func (b *mqttBridge) stopping() bool {
+	select {
+	case <-b.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// handle wakes the alias a message was published for. Retained messages are
+// ignored, they would wake the alias every time the bridge connects.
+func (b *mqttBridge) handle(m mqtt.Message) {
+	alias := strings.TrimPrefix(m.Topic(), b.wakeTopic+"/")
+	if m.Retained() || len(alias) == 0 || alias == m.Topic() {
+		return
+	}
+	stateTopic := b.stateTopic + "/" + alias
+
+	state := mqttWakeState{Alias: alias, Results: []wakeResult{}}
+	store, err := b.open()
+	if err == nil {
+		var results []wakeResult
+		results, err = wakeInNetns(alias, store, cliFlags.DryRun)
+		if results != nil {
+			state.Results = results
+		}
+		store.Close()
+	}
+	if err != nil {
+		state.Error = err.Error()
+	}
+	bs, _ := json.Marshal(state)
+	b.publish(stateTopic, bs, false)
+	fireWakeWebhooks(cliFlags.DryRun, newWakeEvent(sourceMQTT, "", alias, state.Results, err))
+
+	host := strings.TrimSpace(string(m.Payload()))
+	if err != nil || len(host) == 0 {
+		return
+	}
+	timeout := hostStatusTimeout
+	b.publish(stateTopic+"/status", []byte(hostWaking), true)
+	up, status := waitForHost(host, timeout, b.stop), hostDown
+	if b.stopping() {
+		return
+	}
+	if up {
+		status = hostUp
+	}
+	b.publish(stateTopic+"/status", []byte(status), true)
+	fireWakeWebhooks(cliFlags.DryRun, newWaitEvent(sourceMQTT, alias, host, up, timeout))
+}
+
+// Run the mqtt command.
+func mqttCmd(args []string, store aliases.Store) error {
+	if len(cliFlags.WakeTopic) == 0 || len(cliFlags.StateTopic) == 0 {
+		return usageError("mqtt command requires a --wake-topic and a --state-topic")
+	}
+	// Every wake would overwrite the same capture file.
+	if len(cliFlags.Pcap) > 0 {
+		return usageError("--pcap can not be used with the mqtt command")
+	}
+	if _, err := parseMQTTBroker(cliFlags.MQTTBroker); err != nil {
+		return err
+	}
+	hostname, _ := os.Hostname()
+	b := &mqttBridge{
+		broker:     cliFlags.MQTTBroker,
+		wakeTopic:  strings.TrimSuffix(cliFlags.WakeTopic, "/"),
+		stateTopic: strings.TrimSuffix(cliFlags.StateTopic, "/"),
+		clientID:   fmt.Sprintf("wol-%s-%d", hostname, os.Getpid()),
+		open:       storeOpener,
+	}
+
+	// Disconnect cleanly on SIGINT or SIGTERM.
+	stop, signals := make(chan struct{}), make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		sig := <-signals
+		fmt.Fprintf(os.Stdout, "Received %s, shutting down\n", sig)
+		close(stop)
+	}()
+
+	if err := b.run(stop); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "MQTT bridge stopped\n")
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// The MQTT protocol itself is left to the paho client, the bridge only dials
+// the broker so that it can keep an eye on the size of what comes back.
+const (
+	// mqttMaxPacket is the largest packet read from the broker. Wake requests
+	// are tiny, anything near this is a broken or hostile broker.
+	mqttMaxPacket = 64 * 1024
+
+	mqttDialTimeout = 10 * time.Second
+)
+
+// mqttPorts are the default ports of the broker URL schemes, those on 8883
+// use TLS.
+var mqttPorts = map[string]string{
+	"tcp":   "1883",
+	"mqtt":  "1883",
+	"ssl":   "8883",
+	"tls":   "8883",
+	"mqtts": "8883",
+}
+
+// parseMQTTBroker parses and checks the URL of a broker.
+func parseMQTTBroker(broker string) (*url.URL, error) {
+	u, err := url.Parse(broker)
+	if err != nil || len(mqttPorts[u.Scheme]) == 0 || len(u.Host) == 0 {
+		return nil, fmt.Errorf("invalid mqtt broker url %q, expected tcp://host:port or ssl://host:port", broker)
+	}
+	return u, nil
+}
+
+// hostPort adds the port `port` to `host` if it has none.
+func hostPort(host, port string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// dialMQTT connects to the broker at `u`, a URL like `tcp://host:1883`. The
+// `ssl`, `tls` and `mqtts` schemes connect with TLS. It is used by the paho
+// client in place of its own dialer.
+func dialMQTT(u *url.URL, _ mqtt.ClientOptions) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: mqttDialTimeout}
+	addr := hostPort(u.Host, mqttPorts[u.Scheme])
+	var conn net.Conn
+	var err error
+	if mqttPorts[u.Scheme] == "8883" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: u.Hostname()})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mqttLimitConn{Conn: conn}, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// mqttLimitConn follows the framing of the packets read from the broker and
+// fails the connection on any packet longer than mqttMaxPacket, which the
+// client would otherwise allocate in full. Every packet starts with a byte of
+// packet type and flags, followed by its remaining length as a varint of up
+// to 4 bytes.
+type mqttLimitConn struct {
+	net.Conn
+
+	inLength  bool // reading the remaining length
+	length    int
+	shift     uint
+	remaining int // bytes left of the current packet
+}
+
+func (c *mqttLimitConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	for i := 0; i < n; {
+		if c.remaining > 0 {
+			skip := n - i
+			if skip > c.remaining {
+				skip = c.remaining
+			}
+			c.remaining -= skip
+			i += skip
+			continue
+		}
+
+		b := p[i]
+		i++
+		if !c.inLength {
+			c.inLength, c.length, c.shift = true, 0, 0
+			continue
+		}
+		c.length |= int(b&0x7f) << c.shift
+		c.shift += 7
+		if b&0x80 != 0 {
+			if c.shift >= 28 {
+				c.Conn.Close()
+				return 0, errors.New("mqtt: malformed remaining length")
+			}
+			continue
+		}
+		if c.length > mqttMaxPacket {
+			c.Conn.Close()
+			return 0, fmt.Errorf("mqtt: packet of %d bytes exceeds the limit of %d", c.length, mqttMaxPacket)
+		}
+		c.inLength, c.remaining = false, c.length
+	}
+	return n, err
+}
@@ -0,0 +1,56 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// netnsDir is where `ip netns add` creates the named network namespaces.
+const netnsDir = "/var/run/netns"
+
+// netnsPath returns the path of the network namespace `spec`, which is either
+// a path (like /proc/<pid>/ns/net) or the name of a namespace in netnsDir.
+func netnsPath(spec string) string {
+	if strings.ContainsRune(spec, os.PathSeparator) {
+		return spec
+	}
+	return filepath.Join(netnsDir, spec)
+}
+
+// inNetns runs `fn` in the network namespace `spec`. It runs on a goroutine
+// locked to its own OS thread, which is the only thread switched over. The
+// thread is never unlocked, so it is thrown away when `fn` returns instead of
+// going back to the pool of threads in the wrong namespace.
+//
+// Switching namespaces needs CAP_SYS_ADMIN.
+func inNetns(spec string, fn func() error) error {
+	errc := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		path := netnsPath(spec)
+		fd, err := unix.Open(path, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+		if err != nil {
+			errc <- fmt.Errorf("opening network namespace %s: %w", path, err)
+			return
+		}
+		err = unix.Setns(fd, unix.CLONE_NEWNET)
+		unix.Close(fd)
+		if err != nil {
+			errc <- fmt.Errorf("entering network namespace %s: %w", path, err)
+			return
+		}
+
+		errc <- fn()
+	}()
+	return <-errc
+}
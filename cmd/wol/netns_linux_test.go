@@ -0,0 +1,41 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestNetnsPath(t *testing.T) {
+	assert.Equal(t, "/var/run/netns/lab", netnsPath("lab"))
+	assert.Equal(t, "/proc/1/ns/net", netnsPath("/proc/1/ns/net"))
+	assert.Equal(t, "./ns", netnsPath("./ns"))
+}
+
+func TestInNetns(t *testing.T) {
+	called := false
+	err := inNetns("wol-test-missing-netns", func() error {
+		called = true
+		return nil
+	})
+	assert.NotNil(t, err)
+	assert.False(t, called)
+
+	// Entering the namespace we are already in still needs CAP_SYS_ADMIN.
+	stop := errors.New("stop")
+	err = inNetns("/proc/self/ns/net", func() error {
+		called = true
+		return stop
+	})
+	if errors.Is(err, syscall.EPERM) {
+		t.Skip("entering a network namespace needs CAP_SYS_ADMIN")
+	}
+	assert.Equal(t, stop, err)
+	assert.True(t, called)
+}
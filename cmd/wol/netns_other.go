@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// inNetns fails as network namespaces are Linux only.
+func inNetns(spec string, fn func() error) error {
+	return errors.New("network namespaces (--netns) are only supported on Linux")
+}
@@ -0,0 +1,505 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	outputPlain = "plain"
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// result is implemented by everything a command prints. The structured
+// formats (json and yaml) are generated from the value itself, the plain and
+// table formats are up to the result.
+type result interface {
+	// plain writes the human friendly form of the result.
+	plain(w io.Writer)
+
+	// table returns the column headers and rows of the result.
+	table() ([]string, [][]string)
+}
+
+// checkOutputFormat returns an error if `format` is not one we know how to
+// write, so that commands can fail before doing anything.
+func checkOutputFormat(format string) error {
+	switch strings.ToLower(format) {
+	case outputPlain, outputTable, outputJSON, outputYAML, "":
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q (expected one of: %s, %s, %s, %s)",
+		format, outputPlain, outputTable, outputJSON, outputYAML)
+}
+
+// writeOutput renders `res` to `w` in the requested `format`.
+func writeOutput(w io.Writer, format string, res result) error {
+	switch strings.ToLower(format) {
+	case outputPlain, "":
+		res.plain(w)
+	case outputTable:
+		header, rows := res.table()
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case outputJSON:
+		bs, err := json.MarshalIndent(res, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\n", bs)
+	case outputYAML:
+		var buf bytes.Buffer
+		encodeYAML(&buf, reflect.ValueOf(res), "")
+		_, err := w.Write(buf.Bytes())
+		return err
+	default:
+		return checkOutputFormat(format)
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// encodeYAML writes a block style YAML document for `v`. It only handles the
+// kinds of values our results are built from: structs (keyed by their json
+// tags), string keyed maps, slices and scalars.
+func encodeYAML(buf *bytes.Buffer, v reflect.Value, indent string) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			fmt.Fprintf(buf, "%snull\n", indent)
+			return
+		}
+		v = v.Elem()
+	}
+
+	if s, ok := yamlScalar(v); ok {
+		fmt.Fprintf(buf, "%s%s\n", indent, s)
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			var item bytes.Buffer
+			encodeYAML(&item, v.Index(i), indent+"  ")
+			buf.WriteString(indent + "- " + strings.TrimPrefix(item.String(), indent+"  "))
+		}
+	default:
+		keys, values := yamlFields(v)
+		for i, k := range keys {
+			fv := values[i]
+			for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+				if fv.IsNil() {
+					break
+				}
+				fv = fv.Elem()
+			}
+			if s, ok := yamlScalar(fv); ok {
+				fmt.Fprintf(buf, "%s%s: %s\n", indent, k, s)
+				continue
+			}
+			fmt.Fprintf(buf, "%s%s:\n", indent, k)
+			encodeYAML(buf, fv, indent+"  ")
+		}
+	}
+}
+
+// yamlScalar returns the YAML representation of `v` if it is a scalar, or an
+// empty collection (which can be written inline).
+func yamlScalar(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String()), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), true
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			return "[]", true
+		}
+	case reflect.Map:
+		if v.Len() == 0 {
+			return "{}", true
+		}
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return "null", true
+		}
+	}
+	return "", false
+}
+
+// yamlFields returns the keys and values of a struct or map in the order they
+// should be written. Struct fields use the name from their json tag and obey
+// `omitempty` and `-`.
+func yamlFields(v reflect.Value) ([]string, []reflect.Value) {
+	var keys []string
+	var values []reflect.Value
+
+	switch v.Kind() {
+	case reflect.Map:
+		mk := v.MapKeys()
+		sort.Slice(mk, func(i, j int) bool {
+			return fmt.Sprint(mk[i].Interface()) < fmt.Sprint(mk[j].Interface())
+		})
+		for _, k := range mk {
+			keys = append(keys, fmt.Sprint(k.Interface()))
+			values = append(values, v.MapIndex(k))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if len(f.PkgPath) != 0 {
+				continue
+			}
+			name, opts := f.Name, ""
+			if tag := f.Tag.Get("json"); len(tag) > 0 {
+				parts := strings.SplitN(tag, ",", 2)
+				if parts[0] == "-" {
+					continue
+				}
+				if len(parts[0]) > 0 {
+					name = parts[0]
+				}
+				if len(parts) > 1 {
+					opts = parts[1]
+				}
+			}
+			if strings.Contains(opts, "omitempty") && isEmptyValue(v.Field(i)) {
+				continue
+			}
+			keys = append(keys, name)
+			values = append(values, v.Field(i))
+		}
+	}
+	return keys, values
+}
+
+// isEmptyValue mirrors the `omitempty` rules of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// aliasEntry is a single alias as printed by the alias and list commands.
+type aliasEntry struct {
+	Alias string `json:"alias"`
+	Mac   string `json:"mac"`
+	Iface string `json:"iface"`
+	Host  string `json:"host,omitempty"`
+	Layer string `json:"layer,omitempty"`
+}
+
+func (e aliasEntry) plain(w io.Writer) {}
+
+func (e aliasEntry) table() ([]string, [][]string) {
+	return aliasList{e}.table()
+}
+
+// aliasList is the result of the list command, sorted by alias.
+type aliasList []aliasEntry
+
+func (l aliasList) plain(w io.Writer) {
+	if len(l) == 0 {
+		fmt.Fprintf(w, "No aliases found! Add one with \"wol alias <name> <mac>\"\n")
+		return
+	}
+	for _, e := range l {
+		line := fmt.Sprintf("    %s - %s %s", e.Alias, e.Mac, e.Iface)
+		if len(e.Host) > 0 {
+			line += " at " + e.Host
+		}
+		if len(e.Layer) > 0 {
+			line += " (" + e.Layer + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+func (l aliasList) table() ([]string, [][]string) {
+	// The host column is only there when some alias has a host, and the
+	// layer column when a system-wide db is in use.
+	hosts, layered := false, false
+	for _, e := range l {
+		hosts = hosts || len(e.Host) > 0
+		layered = layered || len(e.Layer) > 0
+	}
+
+	header := []string{"ALIAS", "MAC", "INTERFACE"}
+	if hosts {
+		header = append(header, "HOST")
+	}
+	if layered {
+		header = append(header, "LAYER")
+	}
+
+	rows := make([][]string, 0, len(l))
+	for _, e := range l {
+		row := []string{e.Alias, e.Mac, e.Iface}
+		if hosts {
+			row = append(row, e.Host)
+		}
+		if layered {
+			row = append(row, e.Layer)
+		}
+		rows = append(rows, row)
+	}
+	return header, rows
+}
+
+// removeResult is the result of the remove command.
+type removeResult struct {
+	Removed string `json:"removed"`
+}
+
+func (r removeResult) plain(w io.Writer) {}
+
+func (r removeResult) table() ([]string, [][]string) {
+	return []string{"REMOVED"}, [][]string{{r.Removed}}
+}
+
+// moveResult is the result of the rename and copy commands.
+type moveResult struct {
+	Action string `json:"action"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+func (m moveResult) plain(w io.Writer) {}
+
+func (m moveResult) table() ([]string, [][]string) {
+	return []string{"ACTION", "FROM", "TO"}, [][]string{{m.Action, m.From, m.To}}
+}
+
+// configReport is the result of the config show command.
+type configReport struct {
+	Path    string        `json:"path"`
+	Found   bool          `json:"found"`
+	Profile string        `json:"profile"`
+	Entries []configEntry `json:"entries"`
+}
+
+func (c configReport) plain(w io.Writer) {
+	found := ""
+	if !c.Found {
+		found = " (not found)"
+	}
+	fmt.Fprintf(w, "Config file: %s%s\n", c.Path, found)
+	if len(c.Profile) > 0 {
+		fmt.Fprintf(w, "Profile:     %s\n", c.Profile)
+	}
+	// The columns are as wide as the longest name and value.
+	nameWidth, valueWidth := 0, 0
+	for _, e := range c.Entries {
+		if len(e.Name) > nameWidth {
+			nameWidth = len(e.Name)
+		}
+		if len(e.Value) > valueWidth {
+			valueWidth = len(e.Value)
+		}
+	}
+	for _, e := range c.Entries {
+		fmt.Fprintf(w, "    %-*s = %-*s (%s)\n", nameWidth, e.Name, valueWidth, e.Value, e.Source)
+	}
+}
+
+func (c configReport) table() ([]string, [][]string) {
+	rows := make([][]string, 0, len(c.Entries))
+	for _, e := range c.Entries {
+		rows = append(rows, []string{e.Name, e.Value, e.Source})
+	}
+	return []string{"OPTION", "VALUE", "SOURCE"}, rows
+}
+
+// wakeResult describes the outcome of a single wake attempt. For a dry run
+// nothing is sent and the packet which would have been is included instead.
+// Pcap is set to the file the frame was written to in place of sending it.
+type wakeResult struct {
+	Target    string `json:"target"`
+	Mac       string `json:"mac"`
+	Interface string `json:"interface"`
+	Source    string `json:"source,omitempty"`
+	Broadcast string `json:"broadcast"`
+	BytesSent int    `json:"bytes_sent"`
+	Attempts  int    `json:"attempts"`
+	DryRun    bool   `json:"dry_run,omitempty"`
+	Packet    string `json:"packet,omitempty"`
+	Pcap      string `json:"pcap,omitempty"`
+	Error     string `json:"error,omitempty"`
+
+	// BindToDevice is set when the socket is bound to the interface, as it
+	// has no IPv4 address to send from.
+	BindToDevice bool `json:"bind_to_device,omitempty"`
+
+	packet []byte
+}
+
+func (r wakeResult) plain(w io.Writer) {
+	if len(r.Error) > 0 {
+		return
+	}
+	if r.DryRun {
+		fmt.Fprintf(w, "Dry run, no magic packet will be sent\n")
+		fmt.Fprintf(w, "    Target:    %s\n", r.Target)
+		fmt.Fprintf(w, "    MAC:       %s\n", r.Mac)
+		fmt.Fprintf(w, "    Interface: %s\n", valueOr(r.Interface, "(default)"))
+		if r.BindToDevice {
+			fmt.Fprintf(w, "    Source:    (bound to %s)\n", r.Interface)
+		} else {
+			fmt.Fprintf(w, "    Source:    %s\n", valueOr(r.Source, "(any)"))
+		}
+		fmt.Fprintf(w, "    Broadcast: %s\n", r.Broadcast)
+		fmt.Fprintf(w, "    Packet:    %d bytes\n", len(r.packet))
+		fmt.Fprintf(w, "%s", hex.Dump(r.packet))
+	}
+	if len(r.Pcap) > 0 {
+		fmt.Fprintf(w, "Magic packet for MAC %s written to %s\n", r.Mac, r.Pcap)
+	}
+	if r.DryRun || len(r.Pcap) > 0 {
+		return
+	}
+	fmt.Fprintf(w, "Attempting to send a magic packet to MAC %s\n", r.Mac)
+	fmt.Fprintf(w, "... Broadcasting to: %s\n", r.Broadcast)
+	fmt.Fprintf(w, "Magic packet sent successfully to %s\n", r.Mac)
+}
+
+func (r wakeResult) table() ([]string, [][]string) {
+	return []string{"TARGET", "MAC", "INTERFACE", "BROADCAST", "BYTES", "ATTEMPTS", "ERROR"},
+		[][]string{{r.Target, r.Mac, r.Interface, r.Broadcast,
+			strconv.Itoa(r.BytesSent), strconv.Itoa(r.Attempts), r.Error}}
+}
+
+// wakeResults describes the outcome of waking a MAC address or an alias, one
+// result per MAC address.
+type wakeResults []wakeResult
+
+func (rs wakeResults) plain(w io.Writer) {
+	for _, r := range rs {
+		if len(r.Error) > 0 {
+			fmt.Fprintf(w, "Failed to wake MAC %s: %s\n", valueOr(r.Mac, r.Target), r.Error)
+			continue
+		}
+		r.plain(w)
+	}
+}
+
+func (rs wakeResults) table() ([]string, [][]string) {
+	var header []string
+	var rows [][]string
+	for _, r := range rs {
+		h, rr := r.table()
+		header, rows = h, append(rows, rr...)
+	}
+	return header, rows
+}
+
+// statusEntry is the reachability of a single alias, as printed by the status
+// command.
+type statusEntry struct {
+	Alias     string  `json:"alias"`
+	Host      string  `json:"host"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms,omitempty"`
+	Error     string  `json:"error,omitempty"`
+	LastWake  string  `json:"last_wake,omitempty"`
+}
+
+// latency returns the latency for the plain and table outputs.
+func (e statusEntry) latency() string {
+	if e.Status != hostUp {
+		return ""
+	}
+	return strconv.FormatFloat(e.LatencyMS, 'f', 1, 64) + "ms"
+}
+
+// statusList is the result of the status command, sorted by alias.
+type statusList []statusEntry
+
+func (l statusList) plain(w io.Writer) {
+	if len(l) == 0 {
+		fmt.Fprintf(w, "No aliases found! Add one with \"wol alias <name> <mac>\"\n")
+		return
+	}
+	for _, e := range l {
+		woken := ""
+		if len(e.LastWake) > 0 {
+			woken = ", last woken " + e.LastWake
+		}
+		if e.Status == hostUp {
+			fmt.Fprintf(w, "    %s - up %s (%s)%s\n", e.Alias, e.latency(), e.Host, woken)
+			continue
+		}
+		fmt.Fprintf(w, "    %s - down (%s)%s\n", e.Alias, e.Error, woken)
+	}
+}
+
+func (l statusList) table() ([]string, [][]string) {
+	// The last wake column is only there once some alias has been woken.
+	woken := false
+	for _, e := range l {
+		woken = woken || len(e.LastWake) > 0
+	}
+
+	header := []string{"ALIAS", "HOST", "STATUS", "LATENCY", "ERROR"}
+	if woken {
+		header = append(header, "LAST WAKE")
+	}
+	rows := make([][]string, 0, len(l))
+	for _, e := range l {
+		row := []string{e.Alias, e.Host, e.Status, e.latency(), e.Error}
+		if woken {
+			row = append(row, e.LastWake)
+		}
+		rows = append(rows, row)
+	}
+	return header, rows
+}
+
+// valueOr returns `s`, or `def` if it is empty.
+func valueOr(s, def string) string {
+	if len(s) == 0 {
+		return def
+	}
+	return s
+}
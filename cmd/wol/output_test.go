@@ -0,0 +1,314 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/sabhiram/go-wol/wol"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+var testAliasList = aliasList{
+	{"bar", "00:11:22:33:44:56", "", "", ""},
+	{"foo", "00:11:22:33:44:55", "eth0", "", ""},
+}
+
+var testLayeredList = aliasList{
+	{"bar", "00:11:22:33:44:56", "", "", aliases.LayerSystem},
+	{"foo", "00:11:22:33:44:55", "eth0", "foo.lan", aliases.LayerUser},
+}
+
+var testStatusList = statusList{
+	{"bar", "bar:22", hostDown, 0, "connection timed out", ""},
+	{"foo", "foo:22", hostUp, 1.5, "", ""},
+}
+
+var testWokenStatusList = statusList{
+	{"bar", "bar:22", hostDown, 0, "connection timed out", ""},
+	{"foo", "10.0.0.5:22", hostUp, 1.5, "", "2026-10-19T10:00:00Z"},
+}
+
+func TestWriteOutput(t *testing.T) {
+	for _, tc := range []struct {
+		format   string
+		res      result
+		expected string
+	}{
+		{outputPlain, testAliasList, "" +
+			"    bar - 00:11:22:33:44:56 \n" +
+			"    foo - 00:11:22:33:44:55 eth0\n"},
+		{outputPlain, aliasList{}, "No aliases found! Add one with \"wol alias <name> <mac>\"\n"},
+		{outputPlain, testLayeredList, "" +
+			"    bar - 00:11:22:33:44:56  (system)\n" +
+			"    foo - 00:11:22:33:44:55 eth0 at foo.lan (user)\n"},
+		{outputTable, testLayeredList, "" +
+			"ALIAS  MAC                INTERFACE  HOST     LAYER\n" +
+			"bar    00:11:22:33:44:56                      system\n" +
+			"foo    00:11:22:33:44:55  eth0       foo.lan  user\n"},
+		{outputTable, testAliasList, "" +
+			"ALIAS  MAC                INTERFACE\n" +
+			"bar    00:11:22:33:44:56  \n" +
+			"foo    00:11:22:33:44:55  eth0\n"},
+		{outputYAML, testAliasList, "" +
+			"- alias: \"bar\"\n" +
+			"  mac: \"00:11:22:33:44:56\"\n" +
+			"  iface: \"\"\n" +
+			"- alias: \"foo\"\n" +
+			"  mac: \"00:11:22:33:44:55\"\n" +
+			"  iface: \"eth0\"\n"},
+		{outputYAML, aliasList{}, "[]\n"},
+		{outputPlain, configReport{Path: "c.toml", Entries: []configEntry{
+			{"port", "9", "default"},
+			{"system-db-dir", "/etc/go-wol", "default"},
+			{"state-topic", "wol/state", "config"},
+		}}, "" +
+			"Config file: c.toml (not found)\n" +
+			"    port          = 9           (default)\n" +
+			"    system-db-dir = /etc/go-wol (default)\n" +
+			"    state-topic   = wol/state   (config)\n"},
+		{outputYAML, configReport{Path: "c.toml", Entries: []configEntry{{"port", "9", "default"}}}, "" +
+			"path: \"c.toml\"\n" +
+			"found: false\n" +
+			"profile: \"\"\n" +
+			"entries:\n" +
+			"  - name: \"port\"\n" +
+			"    value: \"9\"\n" +
+			"    source: \"default\"\n"},
+		{outputYAML, wakeResults{{Target: "foo", Attempts: 1}}, "" +
+			"- target: \"foo\"\n" +
+			"  mac: \"\"\n" +
+			"  interface: \"\"\n" +
+			"  broadcast: \"\"\n" +
+			"  bytes_sent: 0\n" +
+			"  attempts: 1\n"},
+		{outputJSON, wakeResults{{Target: "foo", Attempts: 1}}, "" +
+			"[\n" +
+			"    {\n" +
+			"        \"target\": \"foo\",\n" +
+			"        \"mac\": \"\",\n" +
+			"        \"interface\": \"\",\n" +
+			"        \"broadcast\": \"\",\n" +
+			"        \"bytes_sent\": 0,\n" +
+			"        \"attempts\": 1\n" +
+			"    }\n" +
+			"]\n"},
+		{outputJSON, aliasList{}, "[]\n"},
+		{outputPlain, testStatusList, "" +
+			"    bar - down (connection timed out)\n" +
+			"    foo - up 1.5ms (foo:22)\n"},
+		{outputTable, testStatusList, "" +
+			"ALIAS  HOST    STATUS  LATENCY  ERROR\n" +
+			"bar    bar:22  down             connection timed out\n" +
+			"foo    foo:22  up      1.5ms    \n"},
+		{outputPlain, testWokenStatusList, "" +
+			"    bar - down (connection timed out)\n" +
+			"    foo - up 1.5ms (10.0.0.5:22), last woken 2026-10-19T10:00:00Z\n"},
+		{outputTable, testWokenStatusList, "" +
+			"ALIAS  HOST         STATUS  LATENCY  ERROR                 LAST WAKE\n" +
+			"bar    bar:22       down             connection timed out  \n" +
+			"foo    10.0.0.5:22  up      1.5ms                          2026-10-19T10:00:00Z\n"},
+		{outputYAML, testStatusList, "" +
+			"- alias: \"bar\"\n" +
+			"  host: \"bar:22\"\n" +
+			"  status: \"down\"\n" +
+			"  error: \"connection timed out\"\n" +
+			"- alias: \"foo\"\n" +
+			"  host: \"foo:22\"\n" +
+			"  status: \"up\"\n" +
+			"  latency_ms: 1.5\n"},
+	} {
+		var buf bytes.Buffer
+		assert.Nil(t, writeOutput(&buf, tc.format, tc.res))
+		assert.Equal(t, tc.expected, buf.String())
+	}
+}
+
+func TestWriteOutputJSON(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, writeOutput(&buf, outputJSON, testAliasList))
+
+	var list aliasList
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &list))
+	assert.Equal(t, testAliasList, list)
+}
+
+func TestWriteOutputUnknown(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NotNil(t, checkOutputFormat("xml"))
+	assert.NotNil(t, writeOutput(&buf, "xml", testAliasList))
+	assert.Equal(t, 0, buf.Len())
+}
+
+// Validates the wake result when sending to a local listener.
+func TestWakeResult(t *testing.T) {
+	saved := cliFlags
+	defer func() { cliFlags = saved }()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	cliFlags.BroadcastIP = "127.0.0.1"
+	cliFlags.UDPPort = strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+
+	store := aliases.NewMemStore()
+	assert.Nil(t, store.Add("foo", "00:11:22:33:44:55", ""))
+
+	results, err := wake("foo", store, false)
+	assert.Nil(t, err)
+	assert.Len(t, results, 1)
+	res := results[0]
+	assert.Equal(t, "foo", res.Target)
+	assert.Equal(t, "00:11:22:33:44:55", res.Mac)
+	assert.Equal(t, "127.0.0.1:"+cliFlags.UDPPort, res.Broadcast)
+	assert.Equal(t, 102, res.BytesSent)
+	assert.Equal(t, 1, res.Attempts)
+
+	bs := make([]byte, 1024)
+	n, _, err := conn.ReadFromUDP(bs)
+	assert.Nil(t, err)
+	assert.Equal(t, 102, n)
+
+	results, err = wake("not-a-mac", store, false)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, results[0].Attempts)
+	assert.Equal(t, err.Error(), results[0].Error)
+}
+
+// Validates that a dry run resolves everything but sends nothing.
+func TestWakeDryRun(t *testing.T) {
+	saved := cliFlags
+	defer func() { cliFlags = saved }()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	cliFlags.BroadcastIP = "127.0.0.1"
+	cliFlags.UDPPort = strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+
+	results, err := wake("0011.2233.4455", aliases.NewMemStore(), true)
+	assert.Nil(t, err)
+	res := results[0]
+	assert.True(t, res.DryRun)
+	assert.Equal(t, "00:11:22:33:44:55", res.Mac)
+	assert.Equal(t, 0, res.Attempts)
+	assert.Equal(t, 0, res.BytesSent)
+	assert.Equal(t, 2*102, len(res.Packet))
+	assert.Equal(t, "ffffffffffff001122334455", res.Packet[:24])
+
+	var buf bytes.Buffer
+	res.plain(&buf)
+	assert.True(t, strings.Contains(buf.String(), "00000000  ff ff ff ff ff ff 00 11  22 33 44 55"))
+
+	// Nothing should have arrived at the listener.
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, _, err = conn.ReadFromUDP(make([]byte, 1024))
+	assert.NotNil(t, err)
+}
+
+// Validates that a pcap file is written in place of sending the packet.
+func TestWakePcap(t *testing.T) {
+	saved := cliFlags
+	defer func() { cliFlags = saved }()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	dir, err := ioutil.TempDir("", "wol-pcap")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	cliFlags.BroadcastIP = "127.0.0.1"
+	cliFlags.UDPPort = strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+
+	for _, tc := range []struct {
+		raw    bool
+		length int
+	}{
+		{false, 14 + 20 + 8 + 102},
+		{true, 14 + 102},
+	} {
+		cliFlags.Pcap = filepath.Join(dir, "out.pcap")
+		cliFlags.Raw = tc.raw
+
+		results, err := wake("00:11:22:33:44:55", aliases.NewMemStore(), false)
+		assert.Nil(t, err)
+		res := results[0]
+		assert.Equal(t, cliFlags.Pcap, res.Pcap)
+		assert.Equal(t, 0, res.Attempts)
+
+		bs, err := ioutil.ReadFile(cliFlags.Pcap)
+		assert.Nil(t, err)
+		assert.Equal(t, 24+16+tc.length, len(bs))
+
+		var buf bytes.Buffer
+		res.plain(&buf)
+		assert.Equal(t, "Magic packet for MAC 00:11:22:33:44:55 written to "+cliFlags.Pcap+"\n", buf.String())
+	}
+
+	// Nothing should have arrived at the listener.
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, _, err = conn.ReadFromUDP(make([]byte, 1024))
+	assert.NotNil(t, err)
+}
+
+// Validates that every MAC address of an alias is woken, even if one fails.
+func TestWakeMultipleMacs(t *testing.T) {
+	saved := cliFlags
+	defer func() { cliFlags = saved }()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	cliFlags.BroadcastIP = "127.0.0.1"
+	cliFlags.UDPPort = strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+
+	store := aliases.NewMemStore()
+	assert.Nil(t, store.Add("srv", "00:11:22:33:44:55", ""))
+	assert.Nil(t, store.AddMac("srv", "00:11:22:33:44:66", "fake-interface-0"))
+	assert.Nil(t, store.AddMac("srv", "00:11:22:33:44:77", ""))
+
+	results, err := wake("srv", store, false)
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "1 of 3 magic packets could not be sent"))
+	assert.Len(t, results, 3)
+	assert.Equal(t, 1, results[0].Attempts)
+	assert.NotEqual(t, "", results[1].Error)
+	assert.Equal(t, 1, results[2].Attempts)
+
+	// Both of the good addresses should have been sent to.
+	var got []string
+	for i := 0; i < 2; i++ {
+		bs := make([]byte, 1024)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFromUDP(bs)
+		assert.Nil(t, err)
+		mac, _, err := wol.ParseMagicPacket(bs[:n])
+		assert.Nil(t, err)
+		got = append(got, mac.String())
+	}
+	assert.Equal(t, []string{"00:11:22:33:44:55", "00:11:22:33:44:77"}, got)
+
+	var buf bytes.Buffer
+	wakeResults(results).plain(&buf)
+	assert.True(t, strings.Contains(buf.String(), "Failed to wake MAC 00:11:22:33:44:66"))
+	headers, rows := wakeResults(results).table()
+	assert.Equal(t, "TARGET", headers[0])
+	assert.Len(t, rows, 3)
+}
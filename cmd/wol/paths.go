@@ -0,0 +1,99 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sabhiram/go-wol/aliases"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	// appDirName is the directory created under the XDG base directories.
+	appDirName = "go-wol"
+
+	// defaultSystemDBDir holds the read-only, system-wide alias db.
+	defaultSystemDBDir = "/etc/go-wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// xdgDefaults are the XDG base directories, relative to the home directory,
+// used when their environment variable is not set to an absolute path.
+var xdgDefaults = map[string]string{
+	"XDG_CONFIG_HOME": ".config",
+	"XDG_DATA_HOME":   filepath.Join(".local", "share"),
+}
+
+// xdgDir returns the go-wol directory under the XDG base directory named by
+// the environment variable `env`. The directory used before XDG paths were
+// honoured (~/.config/go-wol) is returned instead if `name` only exists in
+// that legacy directory.
+func xdgDir(env, home, name string) string {
+	legacy := filepath.Join(home, defaultDBDir)
+
+	base := os.Getenv(env)
+	if !filepath.IsAbs(base) {
+		base = filepath.Join(home, xdgDefaults[env])
+	}
+	dir := filepath.Join(base, appDirName)
+
+	if len(name) > 0 && !fileExists(filepath.Join(dir, name)) && fileExists(filepath.Join(legacy, name)) {
+		return legacy
+	}
+	return dir
+}
+
+// configDir returns the directory holding the per-user config file.
+func configDir(home string) string {
+	return xdgDir("XDG_CONFIG_HOME", home, defaultConfigName)
+}
+
+// dataDir returns the directory holding the per-user alias db `dbName`.
+func dataDir(home, dbName string) string {
+	return xdgDir("XDG_DATA_HOME", home, dbName)
+}
+
+// fileExists reports whether there is a file at `path`.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// openAliasStore opens the per-user alias store at `dbPath`, read-only if
+// `readOnly` is set. If there is a system-wide db of the same kind in the
+// `--system-db-dir` the user store is layered on top of it.
+func openAliasStore(dbPath string, readOnly bool) (aliases.Store, error) {
+	open := aliases.OpenStore
+	if readOnly {
+		open = aliases.OpenStoreReadOnly
+	}
+	store, err := open(cliFlags.Store, dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Layering is skipped for the in-memory store, and when the system db is
+	// the one being edited.
+	dbName := aliases.DefaultDBName(cliFlags.Store)
+	if len(cliFlags.SystemDBDir) == 0 || len(dbName) == 0 {
+		return store, nil
+	}
+	sysPath := filepath.Join(cliFlags.SystemDBDir, dbName)
+	if sysPath == filepath.Clean(dbPath) || !fileExists(sysPath) {
+		return store, nil
+	}
+
+	system, err := aliases.OpenStoreReadOnly(cliFlags.Store, sysPath)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("opening system-wide aliases %s: %w", sysPath, err)
+	}
+	return aliases.NewLayered(store, system), nil
+}
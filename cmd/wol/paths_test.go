@@ -0,0 +1,106 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// setenv sets `key` for the duration of a test, restoring it afterwards.
+func setenv(t *testing.T, key, value string) func() {
+	saved, ok := os.LookupEnv(key)
+	assert.Nil(t, os.Setenv(key, value))
+	return func() {
+		if ok {
+			os.Setenv(key, saved)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestXDGDir(t *testing.T) {
+	home, err := ioutil.TempDir("", "wol-home")
+	assert.Nil(t, err)
+	defer os.RemoveAll(home)
+
+	legacy := filepath.Join(home, defaultDBDir)
+	local := filepath.Join(home, ".local", "share", appDirName)
+	data := filepath.Join(home, "data")
+
+	// Unset and relative paths fall back to the XDG default.
+	defer setenv(t, "XDG_DATA_HOME", "")()
+	assert.Equal(t, local, dataDir(home, "bolt.db"))
+	os.Setenv("XDG_DATA_HOME", "relative/dir")
+	assert.Equal(t, local, dataDir(home, "bolt.db"))
+
+	os.Setenv("XDG_DATA_HOME", data)
+	assert.Equal(t, filepath.Join(data, appDirName), dataDir(home, "bolt.db"))
+
+	// A db which only exists in the legacy directory keeps being used, set
+	// or not.
+	assert.Nil(t, os.MkdirAll(legacy, 0700))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(legacy, "bolt.db"), nil, 0600))
+	assert.Equal(t, legacy, dataDir(home, "bolt.db"))
+	assert.Equal(t, filepath.Join(data, appDirName), dataDir(home, "aliases.json"))
+	os.Unsetenv("XDG_DATA_HOME")
+	assert.Equal(t, legacy, dataDir(home, "bolt.db"))
+	assert.Equal(t, local, dataDir(home, "aliases.json"))
+
+	defer setenv(t, "XDG_CONFIG_HOME", "")()
+	assert.Equal(t, filepath.Join(legacy, defaultConfigName), defaultConfigPath(home))
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+	assert.Equal(t, filepath.Join(home, "config", appDirName, defaultConfigName), defaultConfigPath(home))
+}
+
+// Validates that the user store is layered over a system-wide db, if any.
+func TestOpenAliasStore(t *testing.T) {
+	saved := cliFlags
+	defer func() { cliFlags = saved }()
+
+	dir, err := ioutil.TempDir("", "wol-layers")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	cliFlags.Store = aliases.StoreJSON
+	cliFlags.SystemDBDir = filepath.Join(dir, "etc")
+	userPath := filepath.Join(dir, "user", aliases.DefaultDBName(aliases.StoreJSON))
+	sysPath := filepath.Join(cliFlags.SystemDBDir, aliases.DefaultDBName(aliases.StoreJSON))
+
+	// Without a system db the user store is used as is.
+	store, err := openAliasStore(userPath, true)
+	assert.Nil(t, err)
+	_, layered := store.(*aliases.Layered)
+	assert.False(t, layered)
+	assert.Nil(t, store.Add("laptop", "00:11:22:33:44:55", ""))
+	assert.Nil(t, store.Close())
+
+	// Editing the system db itself does not layer it over itself.
+	system, err := openAliasStore(sysPath, false)
+	assert.Nil(t, err)
+	_, layered = system.(*aliases.Layered)
+	assert.False(t, layered)
+	assert.Nil(t, system.Add("nas", "00:11:22:33:44:66", ""))
+	assert.Nil(t, system.Close())
+
+	store, err = openAliasStore(userPath, true)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	layers, ok := store.(*aliases.Layered)
+	assert.True(t, ok)
+	list, err := store.List()
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+	layer, err := layers.Layer("nas")
+	assert.Nil(t, err)
+	assert.Equal(t, aliases.LayerSystem, layer)
+}
@@ -0,0 +1,327 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/sabhiram/go-colorize"
+	"github.com/sabhiram/go-wol/aliases"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// pickRows is how many aliases the picker shows at once.
+const pickRows = 10
+
+// errPickCancelled is returned when the picker is left without picking.
+var errPickCancelled = errors.New("nothing picked")
+
+// Keys the picker reacts to, anything else printable edits the filter.
+const (
+	keyNone = iota
+	keyRune
+	keyUp
+	keyDown
+	keyToggle
+	keyBackspace
+	keyEnter
+	keyCancel
+)
+
+type pickKey struct {
+	kind int
+	r    rune
+}
+
+// readKey reads a key press from a terminal in raw mode.
+func readKey(r *bufio.Reader) (pickKey, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return pickKey{}, err
+	}
+
+	switch c {
+	case '\r', '\n':
+		return pickKey{kind: keyEnter}, nil
+	case '\t', ' ':
+		return pickKey{kind: keyToggle}, nil
+	case 127, '\b':
+		return pickKey{kind: keyBackspace}, nil
+	case 3, 4: // Ctrl-C, Ctrl-D
+		return pickKey{kind: keyCancel}, nil
+	case 16: // Ctrl-P
+		return pickKey{kind: keyUp}, nil
+	case 14: // Ctrl-N
+		return pickKey{kind: keyDown}, nil
+	case 27:
+		// A lone escape cancels, arrow keys are `ESC [ A` or `ESC O A`.
+		if r.Buffered() == 0 {
+			return pickKey{kind: keyCancel}, nil
+		}
+		if b, _ := r.ReadByte(); b != '[' && b != 'O' {
+			return pickKey{kind: keyNone}, nil
+		}
+		switch b, _ := r.ReadByte(); b {
+		case 'A':
+			return pickKey{kind: keyUp}, nil
+		case 'B':
+			return pickKey{kind: keyDown}, nil
+		}
+		return pickKey{kind: keyNone}, nil
+	}
+	if c < ' ' {
+		return pickKey{kind: keyNone}, nil
+	}
+	return pickKey{kind: keyRune, r: c}, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// pickItem is an alias as listed by the picker.
+type pickItem struct {
+	alias string
+	mac   string
+	iface string
+}
+
+// pickItems returns the aliases of `store` sorted by name.
+func pickItems(store aliases.Store) ([]pickItem, error) {
+	list, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]pickItem, 0, len(list))
+	for alias, mi := range list {
+		items = append(items, pickItem{alias, mi.Mac, mi.Iface})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].alias < items[j].alias })
+	return items, nil
+}
+
+// picker is the state of the interactive picker.
+type picker struct {
+	items    []pickItem
+	filter   string
+	cursor   int
+	selected map[string]bool
+}
+
+func newPicker(items []pickItem, filter string) *picker {
+	return &picker{items: items, filter: filter, selected: map[string]bool{}}
+}
+
+// visible returns the items matching the filter, which is a case-insensitive
+// substring of the alias, MAC or interface.
+func (p *picker) visible() []pickItem {
+	filter := strings.ToLower(p.filter)
+	var out []pickItem
+	for _, it := range p.items {
+		if strings.Contains(strings.ToLower(it.alias+" "+it.mac+" "+it.iface), filter) {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// handle updates the picker for `key`, and reports whether picking is over.
+func (p *picker) handle(key pickKey) bool {
+	visible := p.visible()
+	switch key.kind {
+	case keyRune:
+		p.filter += string(key.r)
+		p.cursor = 0
+	case keyBackspace:
+		if rs := []rune(p.filter); len(rs) > 0 {
+			p.filter = string(rs[:len(rs)-1])
+			p.cursor = 0
+		}
+	case keyUp:
+		if p.cursor > 0 {
+			p.cursor--
+		}
+	case keyDown:
+		if p.cursor < len(visible)-1 {
+			p.cursor++
+		}
+	case keyToggle:
+		if p.cursor < len(visible) {
+			alias := visible[p.cursor].alias
+			p.selected[alias] = !p.selected[alias]
+		}
+	case keyEnter, keyCancel:
+		return true
+	}
+	return false
+}
+
+// chosen returns the selected aliases, or the one under the cursor if none
+// are selected.
+func (p *picker) chosen() []string {
+	var out []string
+	for _, it := range p.items {
+		if p.selected[it.alias] {
+			out = append(out, it.alias)
+		}
+	}
+	if visible := p.visible(); len(out) == 0 && p.cursor < len(visible) {
+		out = append(out, visible[p.cursor].alias)
+	}
+	return out
+}
+
+// render draws the picker and returns how many lines it took.
+func (p *picker) render(w io.Writer) int {
+	visible := p.visible()
+	fmt.Fprintf(w, "%s %s\n", colorize.ColorString("Wake:", "cyan"), p.filter)
+
+	// Scroll so that the cursor stays in view.
+	start := 0
+	if p.cursor >= pickRows {
+		start = p.cursor - pickRows + 1
+	}
+	lines := 1
+	for i := start; i < len(visible) && i < start+pickRows; i++ {
+		it := visible[i]
+		cursor, check := "  ", "[ ]"
+		if i == p.cursor {
+			cursor = colorize.ColorString(">", "yellow") + " "
+		}
+		if p.selected[it.alias] {
+			check = colorize.ColorString("[x]", "green")
+		}
+		fmt.Fprintf(w, "%s%s %s - %s %s\n", cursor, check, colorize.ColorString(it.alias, "yellow"), it.mac, it.iface)
+		lines++
+	}
+	fmt.Fprintf(w, "  %d/%d, arrows to move, space to select, enter to wake, esc to cancel\n", len(visible), len(p.items))
+	return lines + 1
+}
+
+// run shows the picker on the terminal `tty` until enter or escape is pressed.
+func (p *picker) run(in io.Reader, tty io.Writer) ([]string, error) {
+	r := bufio.NewReader(in)
+	lines := 0
+	for {
+		// Redraw over the previous picker.
+		if lines > 0 {
+			fmt.Fprintf(tty, "\033[%dA\r\033[J", lines)
+		}
+		lines = p.render(tty)
+
+		key, err := readKey(r)
+		if err != nil {
+			return nil, err
+		}
+		if p.handle(key) {
+			fmt.Fprintf(tty, "\033[%dA\r\033[J", lines)
+			if key.kind == keyCancel {
+				return nil, errPickCancelled
+			}
+			return p.chosen(), nil
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// pickLine is the fallback when keys can not be read one by one: it prints the
+// numbered aliases to `w` and reads a line of numbers or names from `in`.
+func pickLine(items []pickItem, filter string, in io.Reader, w io.Writer) ([]string, error) {
+	visible := newPicker(items, filter).visible()
+	for i, it := range visible {
+		fmt.Fprintf(w, "%3d) %s - %s %s\n", i+1, it.alias, it.mac, it.iface)
+	}
+	fmt.Fprintf(w, "Wake which aliases (numbers or names)? ")
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	var out []string
+	for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\r' }) {
+		if n, err := strconv.Atoi(field); err == nil {
+			if n < 1 || n > len(visible) {
+				return nil, fmt.Errorf("no alias numbered %d", n)
+			}
+			out = append(out, visible[n-1].alias)
+			continue
+		}
+		found := false
+		for _, it := range items {
+			found = found || it.alias == field
+		}
+		if !found {
+			return nil, fmt.Errorf("%s: %w", field, aliases.ErrAliasNotFound)
+		}
+		out = append(out, field)
+	}
+	if len(out) == 0 {
+		return nil, errPickCancelled
+	}
+	return out, nil
+}
+
+// isInteractive reports whether the picker can talk to a user, the picker is
+// drawn on stderr so that stdout still only has the results.
+func isInteractive() bool {
+	return isatty.IsTerminal(os.Stdin.Fd()) && isatty.IsTerminal(os.Stderr.Fd())
+}
+
+// pickAliases asks the user which aliases to wake.
+func pickAliases(store aliases.Store, filter string) ([]string, error) {
+	items, err := pickItems(store)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errors.New("no aliases to pick from, add one with \"wol alias <name> <mac>\"")
+	}
+
+	if isInteractive() {
+		if restore, err := makeRaw(int(os.Stdin.Fd())); err == nil {
+			defer restore()
+			return newPicker(items, filter).run(os.Stdin, os.Stderr)
+		}
+	}
+
+	// Input which is not typed is not echoed, end the prompt's line.
+	chosen, err := pickLine(items, filter, os.Stdin, os.Stderr)
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		fmt.Fprintln(os.Stderr)
+	}
+	return chosen, err
+}
+
+// Run the pick command.
+func pickCmd(args []string, store aliases.Store) error {
+	chosen, err := pickAliases(store, strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+
+	// Every alias is woken even if some fail.
+	var failed []string
+	var firstErr error
+	for _, alias := range chosen {
+		if err := wakeCmd([]string{alias}, store); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to wake %s: %v\n", alias, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed = append(failed, alias)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to wake %s: %w", strings.Join(failed, ", "), firstErr)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sabhiram/go-colorize"
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+var testPickItems = []pickItem{
+	{"bar", "00:11:22:33:44:56", ""},
+	{"build1", "00:11:22:33:44:57", "eth1"},
+	{"foo", "00:11:22:33:44:55", "eth0"},
+}
+
+func TestReadKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("a\x1b[A\x1b[B\x1bOA\t \x7f\r\x03\x10\x0e\x01é"))
+	for _, expected := range []pickKey{
+		{keyRune, 'a'}, {keyUp, 0}, {keyDown, 0}, {keyUp, 0}, {keyToggle, 0}, {keyToggle, 0},
+		{keyBackspace, 0}, {keyEnter, 0}, {keyCancel, 0}, {keyUp, 0}, {keyDown, 0}, {keyNone, 0},
+		{keyRune, 'é'},
+	} {
+		key, err := readKey(r)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, key)
+	}
+
+	// A lone escape cancels.
+	key, err := readKey(bufio.NewReader(strings.NewReader("\x1b")))
+	assert.Nil(t, err)
+	assert.Equal(t, keyCancel, key.kind)
+}
+
+func TestPickItems(t *testing.T) {
+	store := aliases.NewMemStore()
+	for _, it := range testPickItems {
+		assert.Nil(t, store.Add(it.alias, it.mac, it.iface))
+	}
+	items, err := pickItems(store)
+	assert.Nil(t, err)
+	assert.Equal(t, testPickItems, items)
+}
+
+func TestPicker(t *testing.T) {
+	p := newPicker(testPickItems, "")
+	assert.Len(t, p.visible(), 3)
+
+	// Enter picks the alias under the cursor.
+	p.handle(pickKey{kind: keyDown})
+	assert.True(t, p.handle(pickKey{kind: keyEnter}))
+	assert.Equal(t, []string{"build1"}, p.chosen())
+
+	// The filter matches the alias, MAC or interface.
+	for _, tc := range []struct {
+		filter   string
+		expected int
+	}{
+		{"b", 2},
+		{"ETH", 2},
+		{"44:55", 1},
+		{"nothing", 0},
+	} {
+		assert.Len(t, newPicker(testPickItems, tc.filter).visible(), tc.expected, tc.filter)
+	}
+
+	// Several aliases can be selected, the cursor stays within the list.
+	p = newPicker(testPickItems, "")
+	for _, k := range []int{keyToggle, keyDown, keyDown, keyDown, keyToggle, keyUp, keyToggle, keyToggle} {
+		assert.False(t, p.handle(pickKey{kind: k}))
+	}
+	assert.Equal(t, []string{"bar", "foo"}, p.chosen())
+
+	// Typing filters, backspace undoes it.
+	p = newPicker(testPickItems, "")
+	p.handle(pickKey{kind: keyDown})
+	for _, r := range "fx" {
+		p.handle(pickKey{kind: keyRune, r: r})
+	}
+	assert.Len(t, p.visible(), 0)
+	assert.Len(t, p.chosen(), 0)
+	p.handle(pickKey{kind: keyBackspace})
+	assert.Equal(t, "f", p.filter)
+	assert.Equal(t, []string{"foo"}, p.chosen())
+	assert.True(t, p.handle(pickKey{kind: keyCancel}))
+}
+
+func TestPickerRun(t *testing.T) {
+	saved := colorize.DisableColor
+	defer func() { colorize.DisableColor = saved }()
+	colorize.DisableColor = true
+
+	var tty bytes.Buffer
+	chosen, err := newPicker(testPickItems, "").run(strings.NewReader("bu\r"), &tty)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"build1"}, chosen)
+	assert.True(t, strings.Contains(tty.String(), "> [ ] build1 - 00:11:22:33:44:57 eth1\n"), tty.String())
+	assert.True(t, strings.Contains(tty.String(), "  1/3, arrows to move"), tty.String())
+
+	_, err = newPicker(testPickItems, "").run(strings.NewReader("\x1b"), &tty)
+	assert.Equal(t, errPickCancelled, err)
+	_, err = newPicker(testPickItems, "").run(strings.NewReader(""), &tty)
+	assert.NotNil(t, err)
+}
+
+func TestPickLine(t *testing.T) {
+	for _, tc := range []struct {
+		filter, line string
+		expected     []string
+	}{
+		{"", "1 3\n", []string{"bar", "foo"}},
+		{"", "foo,2", []string{"foo", "build1"}},
+		{"eth", "1", []string{"build1"}},
+	} {
+		var w bytes.Buffer
+		chosen, err := pickLine(testPickItems, tc.filter, strings.NewReader(tc.line), &w)
+		assert.Nil(t, err, tc.line)
+		assert.Equal(t, tc.expected, chosen, tc.line)
+	}
+
+	var w bytes.Buffer
+	_, err := pickLine(testPickItems, "", strings.NewReader("baz\n"), &w)
+	assert.True(t, errors.Is(err, aliases.ErrAliasNotFound))
+	_, err = pickLine(testPickItems, "", strings.NewReader("4\n"), &w)
+	assert.NotNil(t, err)
+	_, err = pickLine(testPickItems, "", strings.NewReader("\n"), &w)
+	assert.Equal(t, errPickCancelled, err)
+	assert.True(t, strings.HasPrefix(w.String(), "  1) bar - 00:11:22:33:44:56 \n"), w.String())
+}
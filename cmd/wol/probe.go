@@ -0,0 +1,55 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	// defaultProbePort is probed on hosts given without a port, most machines
+	// worth waking run ssh.
+	defaultProbePort = "22"
+
+	probeTimeout  = 2 * time.Second
+	probeInterval = 5 * time.Second
+)
+
+// probeTCP connects to `addr` (host or host:port) and returns how long it
+// took. A refused connection still means the host is up.
+func probeTCP(addr string) (time.Duration, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", hostPort(addr, defaultProbePort), probeTimeout)
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return time.Since(start), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	conn.Close()
+	return time.Since(start), nil
+}
+
+// waitForHost probes `addr` until it answers or `timeout` passes, and reports
+// whether it came up. Closing `stop` gives up early.
+func waitForHost(addr string, timeout time.Duration, stop <-chan struct{}) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, err := probeTCP(addr); err == nil {
+			return true
+		}
+		if time.Now().Add(probeInterval).After(deadline) {
+			return false
+		}
+		select {
+		case <-time.After(probeInterval):
+		case <-stop:
+			return false
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sabhiram/go-wol/aliases"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// rtfUp is the RTF_UP flag of routes which are in use.
+const rtfUp = 0x1
+
+// procNetRoute is the Linux IPv4 routing table of the calling thread's network
+// namespace (see --netns), a variable for the tests. Kernels before 3.17 only
+// have the table of the process, procNetRouteFallback.
+var procNetRoute = "/proc/thread-self/net/route"
+
+const procNetRouteFallback = "/proc/net/route"
+
+////////////////////////////////////////////////////////////////////////////////
+
+// route is an entry of the IPv4 routing table.
+type route struct {
+	Iface   string
+	Dest    net.IPNet
+	Gateway net.IP
+	Metric  int
+}
+
+// broadcast returns the directed broadcast address of the route's subnet, or
+// nil for the default route and host routes which have no subnet to broadcast
+// to.
+func (r route) broadcast() net.IP {
+	ones, bits := r.Dest.Mask.Size()
+	if ones == 0 || ones == bits {
+		return nil
+	}
+	ip := make(net.IP, net.IPv4len)
+	for i := range ip {
+		ip[i] = r.Dest.IP.To4()[i] | ^r.Dest.Mask[i]
+	}
+	return ip
+}
+
+// parseProcRoutes reads the routes in use from a table in the format of
+// /proc/net/route.
+func parseProcRoutes(r io.Reader) ([]route, error) {
+	var routes []route
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if line == 1 || len(fields) == 0 {
+			continue
+		}
+		rt, up, err := parseRouteLine(fields)
+		if err != nil {
+			return nil, fmt.Errorf("route table line %d: %w", line, err)
+		}
+		if up {
+			routes = append(routes, rt)
+		}
+	}
+	return routes, scanner.Err()
+}
+
+// parseRouteLine parses the `fields` of a line of /proc/net/route, and reports
+// whether the route is up.
+func parseRouteLine(fields []string) (route, bool, error) {
+	if len(fields) < 8 {
+		return route{}, false, fmt.Errorf("expected 8 or more fields, got %d", len(fields))
+	}
+
+	var addrs [3]net.IP
+	for i, field := range []string{fields[1], fields[2], fields[7]} {
+		ip, err := parseRouteAddr(field)
+		if err != nil {
+			return route{}, false, err
+		}
+		addrs[i] = ip
+	}
+	flags, err := strconv.ParseUint(fields[3], 16, 16)
+	if err != nil {
+		return route{}, false, fmt.Errorf("bad flags %q", fields[3])
+	}
+	metric, err := strconv.Atoi(fields[6])
+	if err != nil {
+		return route{}, false, fmt.Errorf("bad metric %q", fields[6])
+	}
+
+	return route{
+		Iface:   fields[0],
+		Dest:    net.IPNet{IP: addrs[0], Mask: net.IPMask(addrs[2])},
+		Gateway: addrs[1],
+		Metric:  metric,
+	}, flags&rtfUp != 0, nil
+}
+
+// parseRouteAddr parses an IPv4 address from /proc/net/route, which are hex in
+// host (little endian) order.
+func parseRouteAddr(s string) (net.IP, error) {
+	bs, err := hex.DecodeString(s)
+	if err != nil || len(bs) != net.IPv4len {
+		return nil, fmt.Errorf("bad address %q", s)
+	}
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(bs))
+	return ip, nil
+}
+
+// lookupRoute returns the route the kernel would use to reach `ip`: the most
+// specific one, with the lowest metric among those.
+func lookupRoute(routes []route, ip net.IP) (route, bool) {
+	best, found := route{}, false
+	for _, r := range routes {
+		if !r.Dest.Contains(ip) {
+			continue
+		}
+		ones, _ := r.Dest.Mask.Size()
+		bestOnes, _ := best.Dest.Mask.Size()
+		if !found || ones > bestOnes || (ones == bestOnes && r.Metric < best.Metric) {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+// routeTo looks up the route to `ip` in the system's routing table.
+func routeTo(ip net.IP) (route, error) {
+	fp, err := os.Open(procNetRoute)
+	if os.IsNotExist(err) {
+		fp, err = os.Open(procNetRouteFallback)
+	}
+	if err != nil {
+		return route{}, fmt.Errorf("reading the routing table (only supported on Linux): %w", err)
+	}
+	defer fp.Close()
+
+	routes, err := parseProcRoutes(fp)
+	if err != nil {
+		return route{}, err
+	}
+	r, ok := lookupRoute(routes, ip)
+	if !ok {
+		return route{}, fmt.Errorf("no route to %s", ip)
+	}
+	return r, nil
+}
+
+// routeToTarget looks up the route to `target`, an IPv4 address or a host
+// name.
+func routeToTarget(target string) (route, error) {
+	ip := net.ParseIP(target)
+	if ip == nil {
+		ips, err := net.LookupIP(target)
+		if err != nil {
+			return route{}, err
+		}
+		for _, addr := range ips {
+			if addr.To4() != nil {
+				ip = addr
+				break
+			}
+		}
+		if ip == nil {
+			return route{}, fmt.Errorf("%s has no IPv4 address", target)
+		}
+	}
+	if ip.To4() == nil {
+		return route{}, fmt.Errorf("invalid target IP %q, only IPv4 is supported", target)
+	}
+	return routeTo(ip.To4())
+}
+
+// targetRoute returns the route to the machine `mi` is the MAC of, if it is
+// known: the `--target-ip` if one was given, or else the host of the alias.
+// Failing to route to the host of an alias does not stop the wake, since a
+// sleeping machine may well not resolve, and is only a warning if the routing
+// table could be read.
+func targetRoute(mi aliases.MacIface) (route, bool, error) {
+	if cliFlags.TargetIP != "" {
+		rt, err := routeToTarget(cliFlags.TargetIP)
+		return rt, err == nil, err
+	}
+	if mi.Host == "" {
+		return route{}, false, nil
+	}
+
+	rt, err := routeToTarget(mi.Host)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Warning: not routing to the host of %s: %v\n", mi.Mac, err)
+		}
+		return route{}, false, nil
+	}
+	return rt, true, nil
+}
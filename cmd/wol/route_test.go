@@ -0,0 +1,159 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// testRouteTable is a /proc/net/route with a default route through eth0, two
+// on-link subnets, a remote subnet through a gateway and a route which is down.
+const testRouteTable = "" +
+	"Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+	"eth0\t00000000\t0101A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n" +
+	"eth0\t0001A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n" +
+	"wlan0\t0001A8C0\t00000000\t0001\t0\t0\t600\t00FFFFFF\t0\t0\t0\n" +
+	"eth1\t00000A0A\t00000000\t0001\t0\t0\t0\t0000FFFF\t0\t0\t0\n" +
+	"eth1\t00001E0A\t01000A0A\t0003\t0\t0\t0\t0000FFFF\t0\t0\t0\n" +
+	"eth2\t0000140A\t00000000\t0000\t0\t0\t0\t0000FFFF\t0\t0\t0\n"
+
+func TestParseProcRoutes(t *testing.T) {
+	routes, err := parseProcRoutes(strings.NewReader(testRouteTable))
+	assert.Nil(t, err)
+	assert.Len(t, routes, 5)
+	assert.Equal(t, "eth0", routes[0].Iface)
+	assert.Equal(t, "192.168.1.1", routes[0].Gateway.String())
+	assert.Equal(t, "192.168.1.0/24", routes[1].Dest.String())
+	assert.Equal(t, 600, routes[2].Metric)
+	assert.Equal(t, "10.30.0.0/16", routes[4].Dest.String())
+
+	for _, table := range []string{
+		"header\neth0\t00000000\n",
+		"header\neth0\tnothex\t00000000\t0001\t0\t0\t0\t00000000\n",
+		"header\neth0\t00000000\t00000000\tzz\t0\t0\t0\t00000000\n",
+	} {
+		_, err := parseProcRoutes(strings.NewReader(table))
+		assert.NotNil(t, err, table)
+	}
+}
+
+func TestLookupRoute(t *testing.T) {
+	routes, err := parseProcRoutes(strings.NewReader(testRouteTable))
+	assert.Nil(t, err)
+
+	for _, tc := range []struct {
+		ip, iface, broadcast string
+	}{
+		// The lowest metric wins between routes to the same subnet.
+		{"192.168.1.77", "eth0", "192.168.1.255"},
+		{"10.10.3.4", "eth1", "10.10.255.255"},
+
+		// Remote subnets get their directed broadcast, through the gateway.
+		{"10.30.0.9", "eth1", "10.30.255.255"},
+
+		// Only the default route matches, there is no subnet to broadcast to.
+		{"8.8.8.8", "eth0", "<nil>"},
+
+		// Routes which are down are ignored.
+		{"10.20.0.1", "eth0", "<nil>"},
+	} {
+		rt, ok := lookupRoute(routes, net.ParseIP(tc.ip))
+		assert.True(t, ok, tc.ip)
+		assert.Equal(t, tc.iface, rt.Iface, tc.ip)
+		assert.Equal(t, tc.broadcast, rt.broadcast().String(), tc.ip)
+	}
+
+	_, ok := lookupRoute(routes[1:], net.ParseIP("8.8.8.8"))
+	assert.False(t, ok)
+
+	// Host routes have no subnet to broadcast to either.
+	_, dest, _ := net.ParseCIDR("10.40.0.1/32")
+	assert.Nil(t, route{Iface: "tun0", Dest: *dest}.broadcast())
+}
+
+// Validates that planWake takes the interface and broadcast from the routing
+// table, unless they were given explicitly.
+func TestPlanWakeTargetIP(t *testing.T) {
+	saved, savedRoute, savedConfig := cliFlags, procNetRoute, effectiveConfig
+	defer func() { cliFlags, procNetRoute, effectiveConfig = saved, savedRoute, savedConfig }()
+	effectiveConfig.Entries = nil
+
+	dir, err := ioutil.TempDir("", "wol-route")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	procNetRoute = filepath.Join(dir, "route")
+	assert.Nil(t, ioutil.WriteFile(procNetRoute, []byte(testRouteTable), 0600))
+
+	cliFlags.BroadcastIP = "255.255.255.255"
+	cliFlags.UDPPort = "9"
+	cliFlags.TargetIP = "10.30.0.9"
+
+	// The interfaces in the table may not exist here, so only the result is
+	// checked and not whether the interface could be used.
+	res, _, _ := planWake("foo", aliases.MacIface{Mac: "00:11:22:33:44:55"})
+	assert.Equal(t, "eth1", res.Interface)
+	assert.Equal(t, "10.30.255.255:9", res.Broadcast)
+
+	// An explicit broadcast address and interface are kept, even if it is
+	// the default one.
+	for _, src := range []string{"flag", "env", "config"} {
+		effectiveConfig.Entries = []configEntry{{"bcast", "255.255.255.255", src}}
+		res, _, _ = planWake("foo", aliases.MacIface{Mac: "00:11:22:33:44:55"})
+		assert.Equal(t, "255.255.255.255:9", res.Broadcast, src)
+	}
+	cliFlags.BroadcastIP = "127.0.0.1"
+	res, _, err = planWake("foo", aliases.MacIface{Mac: "00:11:22:33:44:55", Iface: "fake-interface-0"})
+	assert.NotNil(t, err)
+	assert.Equal(t, "fake-interface-0", res.Interface)
+	assert.Equal(t, "127.0.0.1:9", res.Broadcast)
+
+	cliFlags.TargetIP = "fe80::1"
+	_, _, err = planWake("foo", aliases.MacIface{Mac: "00:11:22:33:44:55"})
+	assert.NotNil(t, err)
+}
+
+// Validates that planWake routes each MAC of an alias to its own host, and
+// that `--target-ip` overrides it.
+func TestPlanWakeHost(t *testing.T) {
+	saved, savedRoute, savedConfig := cliFlags, procNetRoute, effectiveConfig
+	defer func() { cliFlags, procNetRoute, effectiveConfig = saved, savedRoute, savedConfig }()
+	effectiveConfig.Entries = nil
+
+	dir, err := ioutil.TempDir("", "wol-route")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	procNetRoute = filepath.Join(dir, "route")
+	assert.Nil(t, ioutil.WriteFile(procNetRoute, []byte(testRouteTable), 0600))
+
+	cliFlags.BroadcastIP = "255.255.255.255"
+	cliFlags.UDPPort = "9"
+	cliFlags.TargetIP = ""
+
+	for _, tc := range []struct {
+		host, targetIP, iface, bcast string
+	}{
+		{"10.30.0.9", "", "eth1", "10.30.255.255:9"},
+		{"192.168.1.20", "", "eth0", "192.168.1.255:9"},
+		{"192.168.1.20", "10.30.0.9", "eth1", "10.30.255.255:9"},
+
+		// A host which can not be routed to is not an error, the packet is
+		// broadcast as if there was no host.
+		{"no-such-host.invalid", "", "", "255.255.255.255:9"},
+		{"", "", "", "255.255.255.255:9"},
+	} {
+		cliFlags.TargetIP = tc.targetIP
+		res, _, _ := planWake("foo", aliases.MacIface{Mac: "00:11:22:33:44:55", Host: tc.host})
+		assert.Equal(t, tc.iface, res.Interface, tc.host)
+		assert.Equal(t, tc.bcast, res.Broadcast, tc.host)
+	}
+}
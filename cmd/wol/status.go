@@ -0,0 +1,150 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sabhiram/go-wol/aliases"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	// statusWorkers bounds how many aliases are probed at once.
+	statusWorkers = 16
+
+	// clearScreen moves the cursor home and clears the terminal between
+	// refreshes of `--watch`.
+	clearScreen = "\033[H\033[2J"
+)
+
+// statusTarget is an alias and the host and port to probe it on.
+type statusTarget struct {
+	alias string
+	host  string
+	port  string
+}
+
+// statusTargets returns what to probe for the status command `args`, which
+// are `alias[:port]`. Every alias is probed on defaultProbePort if there are
+// no args.
+func statusTargets(args []string, store aliases.Store) ([]statusTarget, error) {
+	var targets []statusTarget
+	if len(args) == 0 {
+		err := store.ForEachAll(func(alias string, mis []aliases.MacIface) error {
+			targets = append(targets, statusTarget{alias, probeHost(alias, mis), defaultProbePort})
+			return nil
+		})
+		return targets, err
+	}
+
+	for _, arg := range args {
+		t := statusTarget{alias: arg, port: defaultProbePort}
+		if idx := strings.LastIndex(arg, ":"); idx >= 0 {
+			t.alias, t.port = arg[:idx], arg[idx+1:]
+		}
+		mis, err := store.GetAll(t.alias)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", t.alias, err)
+		}
+		t.host = probeHost(t.alias, mis)
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// probeHost returns the host to probe `alias` on: the first host stored with
+// one of its MAC addresses, or else the alias itself as a host name.
+func probeHost(alias string, mis []aliases.MacIface) string {
+	for _, mi := range mis {
+		if len(mi.Host) > 0 {
+			return mi.Host
+		}
+	}
+	return alias
+}
+
+// probeStatus probes the targets concurrently.
+func probeStatus(targets []statusTarget) statusList {
+	list := make(statusList, len(targets))
+	work := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < statusWorkers && w < len(targets); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				t := targets[i]
+				e := statusEntry{Alias: t.alias, Host: net.JoinHostPort(t.host, t.port), Status: hostUp}
+				latency, err := probeTCP(e.Host)
+				if err != nil {
+					e.Status, e.Error = hostDown, err.Error()
+				} else {
+					e.LatencyMS = float64(latency.Microseconds()) / 1000
+				}
+				list[i] = e
+			}
+		}()
+	}
+	for i := range targets {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Alias < list[j].Alias })
+	return list
+}
+
+// addLastWakes fills in when each alias in `list` was last woken.
+func (l statusList) addLastWakes(wakes map[string]time.Time) {
+	for i, e := range l {
+		if at, ok := wakes[e.Alias]; ok {
+			l[i].LastWake = at.Local().Format(time.RFC3339)
+		}
+	}
+}
+
+// Run the status command.
+func statusCmd(args []string, _ aliases.Store) error {
+	for {
+		// The store is only open while looking up the aliases, so that
+		// `--watch` does not keep others from changing them.
+		store, err := storeOpener()
+		if err != nil {
+			return err
+		}
+		targets, err := statusTargets(args, store)
+		store.Close()
+		if err != nil {
+			return err
+		}
+
+		list := probeStatus(targets)
+		wakes, err := loadLastWakes(lastWakePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not read the last wakes: %v\n", err)
+		}
+		list.addLastWakes(wakes)
+		if !cliFlags.Watch {
+			return writeOutput(os.Stdout, cliFlags.Output, list)
+		}
+
+		if format := strings.ToLower(cliFlags.Output); format == outputPlain || format == outputTable || format == "" {
+			fmt.Fprint(os.Stdout, clearScreen)
+			fmt.Fprintf(os.Stdout, "Every %s: %s\n\n", probeInterval, time.Now().Format(time.RFC1123))
+		}
+		if err := writeOutput(os.Stdout, cliFlags.Output, list); err != nil {
+			return err
+		}
+		time.Sleep(probeInterval)
+	}
+}
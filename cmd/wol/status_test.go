@@ -0,0 +1,65 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestStatusTargets(t *testing.T) {
+	store := aliases.NewMemStore()
+	assert.Nil(t, store.Add("foo", "00:11:22:33:44:55", ""))
+	assert.Nil(t, store.Add("bar", "00:11:22:33:44:56", ""))
+	assert.Nil(t, store.AddMac("bar", "00:11:22:33:44:57", ""))
+	assert.Nil(t, store.SetHost("bar", "00:11:22:33:44:57", "10.0.0.5"))
+
+	// Aliases are probed on their stored host, or else by name.
+	targets, err := statusTargets(nil, store)
+	assert.Nil(t, err)
+	assert.Equal(t, []statusTarget{{"bar", "10.0.0.5", "22"}, {"foo", "foo", "22"}}, targets)
+
+	targets, err = statusTargets([]string{"foo:445", "bar"}, store)
+	assert.Nil(t, err)
+	assert.Equal(t, []statusTarget{{"foo", "foo", "445"}, {"bar", "10.0.0.5", "22"}}, targets)
+
+	_, err = statusTargets([]string{"baz:22"}, store)
+	assert.NotNil(t, err)
+}
+
+// Validates probing the hosts of aliases.
+func TestProbeStatus(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	assert.Nil(t, err)
+
+	list := probeStatus([]statusTarget{{"not a host", "not a host", "22"}, {"local", "127.0.0.1", port}})
+	assert.Len(t, list, 2)
+	assert.Equal(t, "local", list[0].Alias)
+	assert.Equal(t, hostUp, list[0].Status)
+	assert.Equal(t, ln.Addr().String(), list[0].Host)
+	assert.Equal(t, "not a host", list[1].Alias)
+	assert.Equal(t, hostDown, list[1].Status)
+	assert.NotEqual(t, "", list[1].Error)
+
+	assert.Len(t, probeStatus(nil), 0)
+}
+
+func TestAddLastWakes(t *testing.T) {
+	at := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	list := statusList{{Alias: "bar"}, {Alias: "foo"}}
+	list.addLastWakes(map[string]time.Time{"foo": at, "baz": at})
+	assert.Equal(t, "", list[0].LastWake)
+	assert.Equal(t, at.Local().Format(time.RFC3339), list[1].LastWake)
+
+	list.addLastWakes(nil)
+	assert.Equal(t, at.Local().Format(time.RFC3339), list[1].LastWake)
+}
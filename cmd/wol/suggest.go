@@ -0,0 +1,162 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/sabhiram/go-wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	// maxSuggestions caps the number of "did you mean" candidates we print.
+	maxSuggestions = 3
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// editDistance returns the Levenshtein distance between `a` and `b`.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// suggestAliases returns the aliases in `names` which are close enough to
+// `target` to likely be what the user meant, best match first.
+func suggestAliases(target string, names []string) []string {
+	// Allow roughly one typo for every three characters, but at least two.
+	limit := len(target) / 3
+	if limit < 2 {
+		limit = 2
+	}
+
+	type candidate struct {
+		name string
+		dist int
+	}
+	var candidates []candidate
+	for _, name := range names {
+		d := editDistance(foldAlias(target), foldAlias(name))
+		if d <= limit {
+			candidates = append(candidates, candidate{name, d})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].dist != candidates[j].dist {
+			return candidates[i].dist < candidates[j].dist
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	var suggestions []string
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		suggestions = append(suggestions, candidates[i].name)
+	}
+	return suggestions
+}
+
+// foldAlias is how aliases are compared when matching them loosely, both for
+// suggestions and for prefixes.
+func foldAlias(name string) string {
+	return strings.ToLower(name)
+}
+
+// prefixMatches returns all of the `names` which start with `prefix`, ignoring
+// case. A name which only differs from `prefix` in case is the only match.
+func prefixMatches(prefix string, names []string) []string {
+	prefix = foldAlias(prefix)
+	var matches []string
+	for _, name := range names {
+		if foldAlias(name) == prefix {
+			return []string{name}
+		}
+		if strings.HasPrefix(foldAlias(name), prefix) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
+
+// quoteAll quotes and joins a list of aliases for use in an error message.
+func quoteAll(names []string, sep string) string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, fmt.Sprintf("%q", name))
+	}
+	return strings.Join(quoted, sep)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// resolveTarget figures out the MAC/interface pairs to wake for `target`, which
+// is either an alias or a mac address, and the name of the alias if it is one.
+// If it is neither, the error suggests aliases the user might have meant. When
+// `fuzzy` is set, a unique alias prefix is also accepted.
+func resolveTarget(target string, store aliases.Store, fuzzy bool) (string, []aliases.MacIface, error) {
+	if mis, err := store.GetAll(target); err == nil {
+		return target, mis, nil
+	}
+	if _, err := wol.ParseMAC(target); err == nil {
+		return "", []aliases.MacIface{{Mac: target}}, nil
+	}
+
+	var names []string
+	err := store.ForEach(func(alias string, mi aliases.MacIface) error {
+		names = append(names, alias)
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	if fuzzy {
+		switch matches := prefixMatches(target, names); len(matches) {
+		case 0:
+		case 1:
+			mis, err := store.GetAll(matches[0])
+			return matches[0], mis, err
+		default:
+			return "", nil, fmt.Errorf("%q is ambiguous, it matches aliases: %s",
+				target, quoteAll(matches, ", "))
+		}
+	}
+
+	hint := ""
+	if suggestions := suggestAliases(target, names); len(suggestions) > 0 {
+		hint = fmt.Sprintf(", did you mean %s?", quoteAll(suggestions, " or "))
+	}
+	return "", nil, fmt.Errorf("%w: %q is neither a known alias nor a valid MAC address%s",
+		aliases.ErrAliasNotFound, target, hint)
+}
@@ -0,0 +1,90 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestEditDistance(t *testing.T) {
+	for _, tc := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"skynet", "skynet", 0},
+		{"skynte", "skynet", 2},
+		{"skynt", "skynet", 1},
+		{"kitten", "sitting", 3},
+	} {
+		assert.Equal(t, tc.expected, editDistance(tc.a, tc.b), tc.a+"/"+tc.b)
+	}
+}
+
+func TestSuggestAliases(t *testing.T) {
+	names := []string{"desktop", "laptop", "nas", "skynet", "skynet2"}
+	assert.Equal(t, []string{"skynet", "skynet2"}, suggestAliases("skynt", names))
+	assert.Equal(t, []string{"laptop"}, suggestAliases("Laptp", names))
+	assert.Equal(t, 0, len(suggestAliases("printer", names)))
+}
+
+func TestResolveTarget(t *testing.T) {
+	store := aliases.NewMemStore()
+	assert.Nil(t, store.Add("skynet", "00:11:22:33:44:55", "eth0"))
+	assert.Nil(t, store.Add("skylab", "00:11:22:33:44:66", ""))
+	assert.Nil(t, store.Add("desktop", "00:11:22:33:44:77", ""))
+
+	alias, mis, err := resolveTarget("skynet", store, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "skynet", alias)
+	assert.Equal(t, []aliases.MacIface{{Mac: "00:11:22:33:44:55", Iface: "eth0"}}, mis)
+
+	alias, mis, err = resolveTarget("00:11:22:33:44:88", store, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "", alias)
+	assert.Equal(t, []aliases.MacIface{{Mac: "00:11:22:33:44:88"}}, mis)
+
+	_, _, err = resolveTarget("skynte", store, false)
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), `did you mean "skynet"?`), err.Error())
+
+	_, _, err = resolveTarget("printer", store, false)
+	assert.NotNil(t, err)
+	assert.False(t, strings.Contains(err.Error(), "did you mean"))
+
+	// Prefixes are only accepted in fuzzy mode, and must be unique.
+	_, _, err = resolveTarget("desk", store, false)
+	assert.NotNil(t, err)
+	alias, mis, err = resolveTarget("desk", store, true)
+	assert.Nil(t, err)
+	assert.Equal(t, "desktop", alias)
+	assert.Equal(t, "00:11:22:33:44:77", mis[0].Mac)
+	_, _, err = resolveTarget("sky", store, true)
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "ambiguous"))
+
+	// Prefixes ignore case just like the suggestions do.
+	for _, target := range []string{"DESK", "SkyN", "SKYNET"} {
+		_, _, err = resolveTarget(target, store, false)
+		assert.NotNil(t, err, target)
+		_, mis, err = resolveTarget(target, store, true)
+		assert.Nil(t, err, target)
+		assert.Len(t, mis, 1, target)
+	}
+}
+
+func TestPrefixMatches(t *testing.T) {
+	names := []string{"nas", "nas2", "Printer"}
+	assert.Equal(t, []string{"nas", "nas2"}, prefixMatches("NA", names))
+	assert.Equal(t, []string{"nas"}, prefixMatches("NAS", names))
+	assert.Equal(t, []string{"Printer"}, prefixMatches("print", names))
+	assert.Len(t, prefixMatches("x", names), 0)
+}
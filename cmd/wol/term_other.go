@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// makeRaw fails as raw mode is only implemented on Linux, the picker reads a
+// line instead.
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode is only supported on Linux")
+}
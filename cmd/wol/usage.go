@@ -17,9 +17,21 @@ var (
 		name, description string
 	}{
 		{`wake`, `wakes up a machine by mac address or alias`},
+		{`pick`, `picks aliases to wake from a list`},
 		{`list`, `lists all mac addresses and their aliases`},
 		{`alias`, `stores an alias to a mac address`},
 		{`remove`, `removes an alias or a mac address`},
+		{`rename`, `renames an alias`},
+		{`copy`, `copies an alias to a new name`},
+		{`edit`, `changes the mac or interface of an alias`},
+		{`status`, `shows which aliases are up and when they were last woken`},
+		{`check`, `checks stored aliases for invalid mac addresses`},
+		{`inspect`, `finds magic packets in a pcap or pcapng capture`},
+		{`config`, `shows the effective configuration`},
+		{`agent`, `serves wake requests for a central server`},
+		{`server`, `dispatches wake requests to remote agents`},
+		{`mqtt`, `wakes aliases on request from an MQTT broker`},
+		{`completion`, `generates a bash, zsh or fish completion script`},
 	}
 
 	validOptions = []struct {
@@ -28,11 +40,31 @@ var (
 		{`v`, `version`, `prints the application version`},
 		{`h`, `help`, `prints this help menu`},
 		{`d`, `db-dir`, `directory to store alias db`},
-		{`a`, `db-name`, `bold db file name (default "bolt.db")`},
-		{`c`, `no-color`, `disables ANSI color`},
+		{`a`, `db-name`, `alias db file name (default depends on store)`},
+		{`S`, `system-db-dir`, `directory of the read-only system-wide alias db`},
+		{`s`, `store`, `alias store: bolt, json or memory (default "bolt")`},
+		{`n`, `no-color`, `disables ANSI color`},
 		{`p`, `port`, `udp port to send bcast packet to`},
 		{`b`, `bcast`, `broadcast IP to send packet to`},
-		{`i`, `interface`, `outbound interface to broadcast using`},
+		{`N`, `netns`, `network namespace (name or path) to send from, Linux only`},
+		{``, `target-ip`, `IP of the target, overrides the host of the alias to route from`},
+		{`u`, `server`, `URL of the wol server or agent to wake through`},
+		{`T`, `token`, `shared secret of the wol server and its agents`},
+		{`M`, `mqtt-broker`, `MQTT broker url (default "tcp://localhost:1883")`},
+		{`W`, `wake-topic`, `MQTT topic prefix to wake aliases on (default "wol/wake")`},
+		{`A`, `state-topic`, `MQTT topic prefix to publish results to (default "wol/state")`},
+		{`i`, `interface`, `outbound interface (name, IP or CIDR) to broadcast using`},
+		{`f`, `config`, `config file to read defaults from`},
+		{`P`, `profile`, `named network profile from the config file`},
+		{`o`, `output`, `output format: plain, table, json or yaml`},
+		{`m`, `mac`, `new mac address for the edit command`},
+		{`I`, `iface`, `new interface for the edit command`},
+		{`H`, `host`, `IP or host name of the target for the alias, add-mac and edit commands`},
+		{`z`, `fuzzy`, `allow waking an alias by a unique prefix, ignoring case`},
+		{`c`, `watch`, `keep refreshing the status command`},
+		{`D`, `dry-run`, `resolve and print the magic packet without sending it`},
+		{`w`, `pcap`, `write the frame to a pcap file instead of sending it`},
+		{`r`, `raw`, `write a raw 0x0842 ethernet frame to the pcap file`},
 	}
 
 	usageString = `Usage:
@@ -40,8 +72,15 @@ var (
     To wake up a machine:
         <cyan>wol</cyan> [<options>] <yellow>wake</yellow> <mac address | alias> <optional interface>
 
-    To store an alias:
-        <cyan>wol</cyan> [<options>] <yellow>alias</yellow> <alias> <mac address> <optional interface>
+    To pick the aliases to wake from a list (also "wake" without arguments):
+        <cyan>wol</cyan> [<options>] <yellow>pick</yellow> <optional filter>
+
+    To store an alias, optionally with the IP or host name it answers on:
+        <cyan>wol</cyan> [<options>] <yellow>alias</yellow> [--host <host>] <alias> <mac address> <optional interface>
+
+    To add or remove more mac addresses of an alias (all of them are woken):
+        <cyan>wol</cyan> [<options>] <yellow>alias</yellow> [--host <host>] add-mac <alias> <mac address> <optional interface>
+        <cyan>wol</cyan> [<options>] <yellow>alias</yellow> remove-mac <alias> <mac address>
 
     To view aliases:
         <cyan>wol</cyan> [<options>] <yellow>list</yellow>
@@ -49,11 +88,38 @@ var (
     To delete aliases:
         <cyan>wol</cyan> [<options>] <yellow>remove</yellow> <alias>
 
-    The following MAC addresses are valid and will match:
-    01-23-45-56-67-89, 89:AB:CD:EF:00:12, 89:ab:cd:ef:00:12
+    To rename, copy or edit an alias:
+        <cyan>wol</cyan> [<options>] <yellow>rename</yellow> <alias> <new alias>
+        <cyan>wol</cyan> [<options>] <yellow>copy</yellow> <alias> <new alias>
+        <cyan>wol</cyan> [<options>] <yellow>edit</yellow> <alias> [--mac <mac address>] [--iface <interface>] [--host <host>]
+
+    To enable shell completion (bash, zsh or fish):
+        <cyan>wol</cyan> <yellow>completion</yellow> <shell>
+
+    To see which aliases are up and when they were last woken (a TCP connection
+    to the --host of the alias, or else its name, on port 22 unless given):
+        <cyan>wol</cyan> [<options>] <yellow>status</yellow> [--watch] [<alias>[:<port>] ...]
+
+    To check stored aliases for invalid mac addresses:
+        <cyan>wol</cyan> [<options>] <yellow>check</yellow>
 
-    The following MAC addresses are not (yet) valid:
-    1-2-3-4-5-6, 01 23 45 56 67 89
+    To look for magic packets in a capture:
+        <cyan>wol</cyan> [<options>] <yellow>inspect</yellow> <capture.pcap>
+
+    To wake machines on other networks through agents running there:
+        <cyan>wol</cyan> --token <secret> <yellow>server</yellow> <optional listen address>
+        <cyan>wol</cyan> --token <secret> --server <server url> <yellow>agent</yellow> <name> <url> <optional listen address>
+        <cyan>wol</cyan> --token <secret> --server <server url> <yellow>wake</yellow> <alias>
+
+    To wake aliases published to <wake topic>/<alias> on an MQTT broker:
+        <cyan>wol</cyan> [--mqtt-broker <url>] <yellow>mqtt</yellow>
+
+    To view the effective configuration:
+        <cyan>wol</cyan> [<options>] <yellow>config</yellow> show
+
+    The following MAC addresses are valid and will match:
+    01-23-45-56-67-89, 89:AB:CD:EF:00:12, 89:ab:cd:ef:00:12,
+    1-2-3-4-5-6, 01 23 45 56 67 89, 0123.4556.6789, 0123455667ab
 
 Commands:
 %s
@@ -80,7 +146,11 @@ func getAllCommands() string {
 func getAllOptions() string {
 	options := ""
 	for _, o := range validOptions {
-		options += fmt.Sprintf("    <yellow>-%s --%-10s</yellow>    %s\n", o.short, o.long, o.description)
+		short := "  "
+		if len(o.short) > 0 {
+			short = "-" + o.short
+		}
+		options += fmt.Sprintf("    <yellow>%s --%-10s</yellow>    %s\n", short, o.long, o.description)
 	}
 	return options
 }
@@ -0,0 +1,81 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// lastWakeName is the file next to the alias db which records when each alias
+// was last woken, as a JSON object of alias to time.
+const lastWakeName = "last-wake.json"
+
+var (
+	// lastWakePath is where wakes are recorded, nothing is if it is empty.
+	lastWakePath string
+
+	// lastWakeMtx serializes the updates of the agent and MQTT bridge, which
+	// wake concurrently.
+	lastWakeMtx sync.Mutex
+)
+
+// loadLastWakes reads the time each alias was last woken from `path`. There
+// are none if the file does not exist yet.
+func loadLastWakes(path string) (map[string]time.Time, error) {
+	wakes := map[string]time.Time{}
+	if len(path) == 0 {
+		return wakes, nil
+	}
+	bs, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return wakes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return wakes, json.Unmarshal(bs, &wakes)
+}
+
+// recordWake records in `path` that `alias` was woken at `at`. The file is
+// replaced rather than rewritten, so it is never seen half written.
+func recordWake(path, alias string, at time.Time) error {
+	lastWakeMtx.Lock()
+	defer lastWakeMtx.Unlock()
+
+	wakes, err := loadLastWakes(path)
+	if err != nil {
+		return err
+	}
+	wakes[alias] = at.UTC().Truncate(time.Second)
+	bs, err := json.MarshalIndent(wakes, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(bs, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// sentAny reports whether a magic packet went out for any of the `results`.
+func sentAny(results []wakeResult) bool {
+	for _, r := range results {
+		if r.BytesSent > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestRecordWake(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wol-wakelog")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sub", lastWakeName)
+
+	wakes, err := loadLastWakes(path)
+	assert.Nil(t, err)
+	assert.Len(t, wakes, 0)
+
+	first := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	assert.Nil(t, recordWake(path, "foo", first))
+	assert.Nil(t, recordWake(path, "bar", first))
+	assert.Nil(t, recordWake(path, "foo", first.Add(time.Hour)))
+
+	wakes, err = loadLastWakes(path)
+	assert.Nil(t, err)
+	assert.Len(t, wakes, 2)
+	assert.True(t, wakes["foo"].Equal(first.Add(time.Hour)))
+	assert.True(t, wakes["bar"].Equal(first))
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("garbage"), 0600))
+	_, err = loadLastWakes(path)
+	assert.NotNil(t, err)
+	assert.NotNil(t, recordWake(path, "foo", first))
+}
+
+// Validates that only wakes of an alias which sent a packet are recorded.
+func TestWakeRecordsLastWake(t *testing.T) {
+	saved, savedPath := cliFlags, lastWakePath
+	defer func() { cliFlags, lastWakePath = saved, savedPath }()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.Nil(t, err)
+	defer conn.Close()
+	cliFlags.BroadcastIP = "127.0.0.1"
+	cliFlags.UDPPort = strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+
+	dir, err := ioutil.TempDir("", "wol-wakelog")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	lastWakePath = filepath.Join(dir, lastWakeName)
+
+	store := aliases.NewMemStore()
+	assert.Nil(t, store.Add("foo", "00:11:22:33:44:55", ""))
+	assert.Nil(t, store.Add("bar", "00:11:22:33:44:56", ""))
+
+	_, err = wake("foo", store, true)
+	assert.Nil(t, err)
+	_, err = wake("00:11:22:33:44:57", store, false)
+	assert.Nil(t, err)
+	wakes, err := loadLastWakes(lastWakePath)
+	assert.Nil(t, err)
+	assert.Len(t, wakes, 0)
+
+	cliFlags.Fuzzy = true
+	for _, target := range []string{"foo", "BA"} {
+		_, err = wake(target, store, false)
+		assert.Nil(t, err)
+	}
+	wakes, err = loadLastWakes(lastWakePath)
+	assert.Nil(t, err)
+	assert.Len(t, wakes, 2)
+	assert.True(t, time.Since(wakes["foo"]) < time.Minute)
+	assert.True(t, time.Since(wakes["bar"]) < time.Minute)
+}
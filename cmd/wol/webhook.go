@@ -0,0 +1,285 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Webhooks are configured in `[webhooks.<name>]` sections of the config file,
+// and are POSTed a webhookEvent after each wake which sent a packet, and after
+// the MQTT bridge waited for a host to come up. Hooks with a `secret` sign the
+// body with HMAC-SHA256 in the signatureHeader, as `sha256=<hex>`.
+const (
+	eventWake        = "wake"
+	eventWaitUp      = "wait.up"
+	eventWaitTimeout = "wait.timeout"
+
+	// Sources of the wakes.
+	sourceCLI   = "cli"
+	sourceAgent = "agent"
+	sourceMQTT  = "mqtt"
+
+	eventHeader     = "X-Wol-Event"
+	signatureHeader = "X-Wol-Signature"
+
+	// webhookRetries is how many times a failed delivery is retried.
+	webhookRetries = 3
+)
+
+var (
+	// configWebhooks holds the `[webhooks.<name>]` sections of the config file.
+	configWebhooks map[string]map[string]string
+
+	// webhookMinBackoff is the wait before the first retry, it doubles with
+	// every retry after that.
+	webhookMinBackoff = time.Second
+
+	// webhookTimeout bounds the deliveries of an event, retries included, so
+	// that a slow hook holds up a wake for no longer than this.
+	webhookTimeout = 10 * time.Second
+)
+
+// webhookEvent is the body of a webhook. `Text` summarizes the event, for
+// chat services such as Slack which display it as is.
+type webhookEvent struct {
+	Event   string       `json:"event"`
+	Time    time.Time    `json:"time"`
+	Source  string       `json:"source"`
+	Host    string       `json:"host"`
+	User    string       `json:"user,omitempty"`
+	Alias   string       `json:"alias"`
+	Results []wakeResult `json:"results,omitempty"`
+	WaitFor string       `json:"wait_for,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Text    string       `json:"text"`
+}
+
+// newWakeEvent describes the wake of `alias` by `user`.
+func newWakeEvent(source, user, alias string, results []wakeResult, err error) webhookEvent {
+	ev := newWebhookEvent(eventWake, source, user, alias)
+	ev.Results = results
+
+	who := valueOr(user, "someone")
+	if err != nil {
+		ev.Error = err.Error()
+		ev.Text = fmt.Sprintf("%s failed to wake %s on %s: %s", who, alias, ev.Host, ev.Error)
+		return ev
+	}
+	var macs []string
+	for _, r := range results {
+		mac := r.Mac
+		if len(r.Interface) > 0 {
+			mac += " via " + r.Interface
+		}
+		macs = append(macs, mac)
+	}
+	ev.Text = fmt.Sprintf("%s woke %s (%s) on %s", who, alias, strings.Join(macs, ", "), ev.Host)
+	return ev
+}
+
+// newWaitEvent describes whether `alias` came up at `addr` within `timeout`.
+func newWaitEvent(source, alias, addr string, up bool, timeout time.Duration) webhookEvent {
+	ev := newWebhookEvent(eventWaitUp, source, "", alias)
+	ev.WaitFor = addr
+	ev.Text = fmt.Sprintf("%s is up (%s)", alias, addr)
+	if !up {
+		ev.Event = eventWaitTimeout
+		ev.Text = fmt.Sprintf("%s did not come up (%s) within %s", alias, addr, timeout)
+	}
+	return ev
+}
+
+func newWebhookEvent(event, source, user, alias string) webhookEvent {
+	host, _ := os.Hostname()
+	return webhookEvent{
+		Event:  event,
+		Time:   time.Now().UTC(),
+		Source: source,
+		Host:   host,
+		User:   user,
+		Alias:  alias,
+	}
+}
+
+// wakeUser returns the name of the user running wol.
+func wakeUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// webhook is a URL to notify of the `events` (all of them if empty) of the
+// `aliases` (all of them if empty).
+type webhook struct {
+	name    string
+	url     string
+	secret  string
+	events  []string
+	aliases []string
+}
+
+// loadWebhooks returns the webhooks of the config file sorted by name.
+func loadWebhooks(sections map[string]map[string]string) ([]webhook, error) {
+	var hooks []webhook
+	for name, values := range sections {
+		if len(values["url"]) == 0 {
+			return nil, fmt.Errorf("webhook %q has no url in the config", name)
+		}
+		hooks = append(hooks, webhook{
+			name:    name,
+			url:     values["url"],
+			secret:  values["secret"],
+			events:  splitList(values["events"]),
+			aliases: splitList(values["aliases"]),
+		})
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].name < hooks[j].name })
+	return hooks, nil
+}
+
+// splitList splits a comma separated config value.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// wants reports whether the hook is interested in `ev`. Events can be given
+// in full, or by their prefix like "wait".
+func (h webhook) wants(ev webhookEvent) bool {
+	return matchesAny(h.events, ev.Event, strings.SplitN(ev.Event, ".", 2)[0]) &&
+		matchesAny(h.aliases, ev.Alias, ev.Alias)
+}
+
+// matchesAny reports whether `list` is empty or contains `a` or `b`.
+func matchesAny(list []string, a, b string) bool {
+	for _, v := range list {
+		if v == a || v == b {
+			return true
+		}
+	}
+	return len(list) == 0
+}
+
+// signBody returns the signature of `body` with `secret`.
+func signBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliver POSTs `body` to the hook, retrying with a backoff on network errors,
+// server errors and rate limiting until `ctx` is done.
+func (h webhook) deliver(ctx context.Context, client *http.Client, event string, body []byte) error {
+	backoff := webhookMinBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := h.post(ctx, client, event, body)
+		if err == nil || !retry || attempt == webhookRetries {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// post makes a single delivery attempt and reports whether it is worth
+// retrying if it failed.
+func (h webhook) post(ctx context.Context, client *http.Client, event string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(eventHeader, event)
+	if len(h.secret) > 0 {
+		req.Header.Set(signatureHeader, signBody(h.secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode/100 == 2:
+		return false, nil
+	case resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("%s: %s", h.url, resp.Status)
+	default:
+		return false, fmt.Errorf("%s: %s", h.url, resp.Status)
+	}
+}
+
+// fireWakeWebhooks fires the webhooks for a wake which went out. Dry runs and
+// wakes written to a pcap file send nothing, so nobody is notified of them.
+func fireWakeWebhooks(dryRun bool, ev webhookEvent) {
+	if dryRun || len(cliFlags.Pcap) > 0 {
+		return
+	}
+	fireWebhooks(ev)
+}
+
+// fireWebhooks delivers `ev` to the configured webhooks which want it, and
+// waits for them for up to webhookTimeout. Failures are only reported, they
+// never fail the wake.
+func fireWebhooks(ev webhookEvent) {
+	if len(configWebhooks) == 0 {
+		return
+	}
+	hooks, err := loadWebhooks(configWebhooks)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return
+	}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, h := range hooks {
+		if !h.wants(ev) {
+			continue
+		}
+		wg.Add(1)
+		go func(h webhook) {
+			defer wg.Done()
+			if err := h.deliver(ctx, httpClient, ev.Event, body); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: webhook %s failed: %v\n", h.name, err)
+			}
+		}(h)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,221 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sabhiram/go-wol/aliases"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// testHookServer records the webhooks it receives, failing the first `fail`
+// deliveries with `code`.
+type testHookServer struct {
+	mtx      sync.Mutex
+	fail     int
+	code     int
+	attempts int
+	received []*http.Request
+	bodies   [][]byte
+}
+
+func (s *testHookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.attempts++
+	if s.attempts <= s.fail {
+		w.WriteHeader(s.code)
+		return
+	}
+	s.received, s.bodies = append(s.received, r), append(s.bodies, body)
+}
+
+func TestWakeEvent(t *testing.T) {
+	ev := newWakeEvent(sourceCLI, "alice", "nas", []wakeResult{
+		{Target: "nas", Mac: "00:11:22:33:44:55", Interface: "eth0"},
+		{Target: "nas", Mac: "66:77:88:99:aa:bb"},
+	}, nil)
+	assert.Equal(t, eventWake, ev.Event)
+	assert.Equal(t, "alice", ev.User)
+	assert.True(t, strings.HasPrefix(ev.Text, "alice woke nas (00:11:22:33:44:55 via eth0, 66:77:88:99:aa:bb) on "), ev.Text)
+
+	ev = newWakeEvent(sourceMQTT, "", "nas", nil, errors.New("boom"))
+	assert.Equal(t, "boom", ev.Error)
+	assert.True(t, strings.HasPrefix(ev.Text, "someone failed to wake nas on "), ev.Text)
+
+	ev = newWaitEvent(sourceMQTT, "nas", "nas.lan:22", false, time.Minute)
+	assert.Equal(t, eventWaitTimeout, ev.Event)
+	assert.Equal(t, "nas did not come up (nas.lan:22) within 1m0s", ev.Text)
+	assert.Equal(t, eventWaitUp, newWaitEvent(sourceMQTT, "nas", "nas.lan:22", true, time.Minute).Event)
+}
+
+func TestWebhookWants(t *testing.T) {
+	wake := newWakeEvent(sourceCLI, "alice", "nas", nil, nil)
+	up := newWaitEvent(sourceMQTT, "nas", "nas.lan", true, time.Minute)
+	other := newWakeEvent(sourceCLI, "alice", "printer", nil, nil)
+
+	for _, tc := range []struct {
+		hook     webhook
+		expected []bool
+	}{
+		{webhook{}, []bool{true, true, true}},
+		{webhook{events: []string{"wake"}}, []bool{true, false, true}},
+		{webhook{events: []string{"wait"}}, []bool{false, true, false}},
+		{webhook{events: []string{"wait.timeout"}}, []bool{false, false, false}},
+		{webhook{aliases: []string{"nas"}}, []bool{true, true, false}},
+	} {
+		for i, ev := range []webhookEvent{wake, up, other} {
+			assert.Equal(t, tc.expected[i], tc.hook.wants(ev), tc.hook, ev.Event, ev.Alias)
+		}
+	}
+}
+
+// Validates the delivery, signature and retries of webhooks.
+func TestFireWebhooks(t *testing.T) {
+	savedHooks, savedBackoff := configWebhooks, webhookMinBackoff
+	defer func() { configWebhooks, webhookMinBackoff = savedHooks, savedBackoff }()
+	webhookMinBackoff = time.Millisecond
+
+	signed := &testHookServer{fail: 2, code: http.StatusServiceUnavailable}
+	rejected := &testHookServer{fail: 10, code: http.StatusNotFound}
+	filtered := &testHookServer{}
+	srvs := map[string]*httptest.Server{}
+	for name, h := range map[string]*testHookServer{"signed": signed, "rejected": rejected, "filtered": filtered} {
+		srvs[name] = httptest.NewServer(h)
+		defer srvs[name].Close()
+	}
+	configWebhooks = map[string]map[string]string{
+		"signed":   {"url": srvs["signed"].URL, "secret": "s3cret"},
+		"rejected": {"url": srvs["rejected"].URL},
+		"filtered": {"url": srvs["filtered"].URL, "aliases": "printer"},
+	}
+
+	fireWebhooks(newWakeEvent(sourceCLI, "alice", "nas", []wakeResult{{Target: "nas", Mac: "00:11:22:33:44:55"}}, nil))
+
+	// Server errors are retried, other failures are not.
+	assert.Equal(t, 3, signed.attempts)
+	assert.Equal(t, 1, rejected.attempts)
+	assert.Equal(t, 0, filtered.attempts)
+
+	assert.Len(t, signed.received, 1)
+	r, body := signed.received[0], signed.bodies[0]
+	assert.Equal(t, eventWake, r.Header.Get(eventHeader))
+	assert.Equal(t, signBody("s3cret", body), r.Header.Get(signatureHeader))
+	assert.NotEqual(t, signBody("wrong", body), r.Header.Get(signatureHeader))
+
+	var ev webhookEvent
+	assert.Nil(t, json.Unmarshal(body, &ev))
+	assert.Equal(t, "nas", ev.Alias)
+	assert.Equal(t, "alice", ev.User)
+	assert.Equal(t, sourceCLI, ev.Source)
+	assert.Equal(t, "00:11:22:33:44:55", ev.Results[0].Mac)
+
+	// Retries give up eventually.
+	failing := &testHookServer{fail: 10, code: http.StatusTooManyRequests}
+	srv := httptest.NewServer(failing)
+	defer srv.Close()
+	assert.NotNil(t, webhook{url: srv.URL}.deliver(context.Background(), httpClient, eventWake, []byte("{}")))
+	assert.Equal(t, webhookRetries+1, failing.attempts)
+
+	_, err := loadWebhooks(map[string]map[string]string{"nourl": {}})
+	assert.NotNil(t, err)
+}
+
+// Validates that slow hooks and long backoffs give up at webhookTimeout.
+func TestWebhookTimeout(t *testing.T) {
+	savedHooks, savedBackoff, savedTimeout := configWebhooks, webhookMinBackoff, webhookTimeout
+	defer func() { configWebhooks, webhookMinBackoff, webhookTimeout = savedHooks, savedBackoff, savedTimeout }()
+	webhookMinBackoff, webhookTimeout = time.Hour, 100*time.Millisecond
+
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hanging.Close()
+	defer close(release)
+	failing := &testHookServer{fail: 10, code: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(failing)
+	defer srv.Close()
+
+	configWebhooks = map[string]map[string]string{
+		"hanging": {"url": hanging.URL},
+		"failing": {"url": srv.URL},
+	}
+	start := time.Now()
+	fireWebhooks(newWakeEvent(sourceCLI, "alice", "nas", nil, nil))
+	assert.True(t, time.Since(start) < 5*time.Second, time.Since(start))
+	assert.Equal(t, 1, failing.attempts)
+}
+
+// Validates that dry runs and wakes written to a pcap file post nothing, from
+// the cli and through an agent.
+func TestFireWakeWebhooks(t *testing.T) {
+	saved, savedHooks := cliFlags, configWebhooks
+	defer func() { cliFlags, configWebhooks = saved, savedHooks }()
+
+	hook := &testHookServer{}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+	configWebhooks = map[string]map[string]string{"all": {"url": srv.URL}}
+	posted := func() int {
+		hook.mtx.Lock()
+		defer hook.mtx.Unlock()
+		return len(hook.received)
+	}
+
+	_, agentSrv, conn := testAgent(t, "lab", "s3cret")
+	defer agentSrv.Close()
+	defer conn.Close()
+
+	dir, err := ioutil.TempDir("", "wol-webhook")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	store := aliases.NewMemStore()
+	assert.Nil(t, store.Add("foo", "00:11:22:33:44:55", ""))
+	cliFlags.Output = outputJSON
+	for _, tc := range []struct {
+		dryRun bool
+		pcap   string
+		posted int
+	}{
+		{true, "", 0},
+		{false, filepath.Join(dir, "out.pcap"), 0},
+		{false, "", 1},
+	} {
+		cliFlags.DryRun, cliFlags.Pcap = tc.dryRun, tc.pcap
+		assert.Nil(t, wakeCmd([]string{"foo"}, store))
+		assert.Equal(t, tc.posted, posted(), tc.dryRun, tc.pcap)
+	}
+
+	// Agents answer before firing the webhooks, so wait for the real wake
+	// which follows the dry run.
+	cliFlags.DryRun, cliFlags.Pcap = false, ""
+	for _, dryRun := range []bool{true, false} {
+		_, err := remoteWake(httpClient, agentSrv.URL, "s3cret", wakeRequest{Target: "foo", DryRun: dryRun})
+		assert.Nil(t, err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); posted() < 2; {
+		if time.Now().After(deadline) {
+			t.Fatal("agent did not post the wake")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 2, posted())
+}
@@ -3,6 +3,7 @@ package main
 ////////////////////////////////////////////////////////////////////////////////
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net"
@@ -10,9 +11,11 @@ import (
 	"os/user"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/mattn/go-colorable"
 	"github.com/sabhiram/go-colorize"
+	"github.com/sabhiram/go-wol/aliases"
 	"github.com/sabhiram/go-wol/wol"
 
 	flags "github.com/jessevdk/go-flags"
@@ -29,11 +32,31 @@ var (
 	cliFlags struct {
 		Version            bool   `short:"v" long:"version"`
 		DBDir              string `short:"d" long:"db-dir" default:""`
-		DBName             string `short:"a" long:"db-name" default:"bolt.db"`
+		DBName             string `short:"a" long:"db-name" default:""`
+		SystemDBDir        string `short:"S" long:"system-db-dir" default:"/etc/go-wol"`
+		Store              string `short:"s" long:"store" default:"bolt"`
+		Config             string `short:"f" long:"config" default:""`
+		Profile            string `short:"P" long:"profile" default:""`
+		Output             string `short:"o" long:"output" default:"plain"`
+		EditMac            string `short:"m" long:"mac" default:""`
+		EditIface          string `short:"I" long:"iface" default:""`
+		Host               string `short:"H" long:"host" default:""`
+		Fuzzy              bool   `short:"z" long:"fuzzy"`
+		DryRun             bool   `short:"D" long:"dry-run"`
+		Pcap               string `short:"w" long:"pcap" default:""`
+		Raw                bool   `short:"r" long:"raw"`
 		Help               bool   `short:"h" long:"help"`
 		NoColor            bool   `short:"n" long:"no-color"`
 		BroadcastInterface string `short:"i" long:"interface" default:""`
 		BroadcastIP        string `short:"b" long:"bcast" default:"255.255.255.255"`
+		TargetIP           string `long:"target-ip" default:""`
+		Netns              string `short:"N" long:"netns" default:""`
+		Server             string `short:"u" long:"server" default:""`
+		Token              string `short:"T" long:"token" default:""`
+		MQTTBroker         string `short:"M" long:"mqtt-broker" default:"tcp://localhost:1883"`
+		WakeTopic          string `short:"W" long:"wake-topic" default:"wol/wake"`
+		StateTopic         string `short:"A" long:"state-topic" default:"wol/state"`
+		Watch              bool   `short:"c" long:"watch"`
 		UDPPort            string `short:"p" long:"port" default:"9"`
 	}
 	stdout = colorable.NewColorableStdout()
@@ -41,166 +64,597 @@ var (
 
 ////////////////////////////////////////////////////////////////////////////////
 
-// ipFromInterface returns a `*net.UDPAddr` from a network interface name.
+// ipFromInterface returns a `*net.UDPAddr` from a network interface name, IP
+// address or CIDR, see selectInterface.
 func ipFromInterface(iface string) (*net.UDPAddr, error) {
-	ief, err := net.InterfaceByName(iface)
+	ia, err := resolveInterface(iface)
 	if err != nil {
 		return nil, err
 	}
-
-	addrs, err := ief.Addrs()
-	if err == nil && len(addrs) <= 0 {
-		err = fmt.Errorf("no address associated with interface %s", iface)
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	// Validate that one of the addrs is a valid network IP address.
-	for _, addr := range addrs {
-		switch ip := addr.(type) {
-		case *net.IPNet:
-			if !ip.IP.IsLoopback() && ip.IP.To4() != nil {
-				return &net.UDPAddr{
-					IP: ip.IP,
-				}, nil
-			}
-		}
-	}
-	return nil, fmt.Errorf("no address associated with interface %s", iface)
+	return &net.UDPAddr{
+		IP: ia.IPNet.IP,
+	}, nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// Sub-commands of alias which manage the MAC addresses of an existing alias.
+const (
+	aliasAddMac    = "add-mac"
+	aliasRemoveMac = "remove-mac"
+)
+
 // Run the alias command.
-func aliasCmd(args []string, aliases *Aliases) error {
+func aliasCmd(args []string, store aliases.Store) error {
+	if len(args) > 0 {
+		switch strings.ToLower(args[0]) {
+		case aliasAddMac:
+			return aliasAddMacCmd(args[1:], store)
+		case aliasRemoveMac:
+			return aliasRemoveMacCmd(args[1:], store)
+		}
+	}
+
 	if len(args) >= 2 {
 		var eth string
 		if len(args) > 2 {
 			eth = args[2]
 		}
-		// TODO: Validate mac address
-		alias, mac := args[0], args[1]
-		return aliases.Add(alias, mac, eth)
+		// Validate the mac address so that we don't store something which
+		// will only fail at wake time.
+		alias := args[0]
+		mac, warning, err := validateMAC(args[1])
+		if err != nil {
+			return err
+		}
+		if len(warning) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+		}
+		host, err := hostFlag()
+		if err != nil {
+			return err
+		}
+		if err := store.Add(alias, mac, eth); err != nil {
+			return err
+		}
+		if len(host) > 0 {
+			if err := store.SetHost(alias, "", host); err != nil {
+				return err
+			}
+		}
+		return writeOutput(os.Stdout, cliFlags.Output, aliasEntry{alias, mac, eth, host, ""})
 	}
-	return errors.New("alias command requires a <name> and a <mac>")
+	return usageError("alias command requires a <name> and a <mac>")
 }
 
-// Run the list command.
-func listCmd(args []string, aliases *Aliases) error {
-	mp, err := aliases.List()
+// hostFlag returns the validated `--host`. The host is where the machine
+// answers once it is up, so it must be an IPv4 address or a host name.
+func hostFlag() (string, error) {
+	host := cliFlags.Host
+	if len(host) == 0 {
+		return "", nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		return "", fmt.Errorf("invalid host %q, only IPv4 addresses are supported", host)
+	}
+	if strings.ContainsAny(host, ": /\t") {
+		return "", fmt.Errorf("invalid host %q, expected an IPv4 address or a host name", host)
+	}
+	return host, nil
+}
+
+// Run the alias add-mac command.
+func aliasAddMacCmd(args []string, store aliases.Store) error {
+	if len(args) < 2 {
+		return usageError("alias add-mac requires an <alias> and a <mac>")
+	}
+	var eth string
+	if len(args) > 2 {
+		eth = args[2]
+	}
+
+	alias := args[0]
+	mac, warning, err := validateMAC(args[1])
+	if err != nil {
+		return err
+	}
+	if len(warning) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	host, err := hostFlag()
+	if err != nil {
+		return err
+	}
+	if err := store.AddMac(alias, mac, eth); err != nil {
+		return err
+	}
+	if len(host) > 0 {
+		if err := store.SetHost(alias, mac, host); err != nil {
+			return err
+		}
+	}
+	return writeAliasMacs(alias, store)
+}
+
+// Run the alias remove-mac command.
+func aliasRemoveMacCmd(args []string, store aliases.Store) error {
+	if len(args) < 2 {
+		return usageError("alias remove-mac requires an <alias> and a <mac>")
+	}
+	if err := store.RemoveMac(args[0], args[1]); err != nil {
+		return err
+	}
+	return writeAliasMacs(args[0], store)
+}
+
+// writeAliasMacs prints all of the MAC/interface pairs of `alias`.
+func writeAliasMacs(alias string, store aliases.Store) error {
+	mis, err := store.GetAll(alias)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to get list of aliases: %v\n", err)
 		return err
 	}
-	if len(mp) == 0 {
-		fmt.Printf("No aliases found! Add one with \"wol alias <name> <mac>\"\n")
-	} else {
-		for alias, mi := range mp {
-			fmt.Printf("    %s - %s %s\n", alias, mi.Mac, mi.Iface)
+	list := aliasList{}
+	for _, mi := range mis {
+		list = append(list, aliasEntry{alias, mi.Mac, mi.Iface, mi.Host, ""})
+	}
+	return writeOutput(os.Stdout, cliFlags.Output, list)
+}
+
+// Run the list command.
+func listCmd(args []string, store aliases.Store) error {
+	// Name the layer of each entry when a system-wide db is in use.
+	layered, _ := store.(*aliases.Layered)
+
+	list := aliasList{}
+	err := store.ForEachAll(func(alias string, mis []aliases.MacIface) error {
+		layer := ""
+		if layered != nil {
+			var err error
+			if layer, err = layered.Layer(alias); err != nil {
+				return err
+			}
+		}
+		for _, mi := range mis {
+			list = append(list, aliasEntry{alias, mi.Mac, mi.Iface, mi.Host, layer})
 		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get list of aliases: %v\n", err)
+		return err
 	}
-	return nil
+	return writeOutput(os.Stdout, cliFlags.Output, list)
 }
 
 // Run the remove command.
-func removeCmd(args []string, aliases *Aliases) error {
+func removeCmd(args []string, store aliases.Store) error {
 	if len(args) > 0 {
 		alias := args[0]
-		return aliases.Del(alias)
+		if err := store.Del(alias); err != nil {
+			return err
+		}
+		return writeOutput(os.Stdout, cliFlags.Output, removeResult{alias})
+	}
+	return usageError("remove command requires a <name> of an alias")
+}
+
+// Run the rename command.
+func renameCmd(args []string, store aliases.Store) error {
+	if len(args) < 2 {
+		return usageError("rename command requires an <old> and a <new> alias")
+	}
+	if err := store.Rename(args[0], args[1]); err != nil {
+		return err
+	}
+	return writeOutput(os.Stdout, cliFlags.Output, moveResult{"rename", args[0], args[1]})
+}
+
+// Run the copy command.
+func copyCmd(args []string, store aliases.Store) error {
+	if len(args) < 2 {
+		return usageError("copy command requires a <source> and a <target> alias")
+	}
+	if err := store.Copy(args[0], args[1]); err != nil {
+		return err
+	}
+	return writeOutput(os.Stdout, cliFlags.Output, moveResult{"copy", args[0], args[1]})
+}
+
+// Run the edit command. Only the fields given on the command line are
+// changed, everything else is kept as is.
+func editCmd(args []string, store aliases.Store) error {
+	if len(args) < 1 {
+		return usageError("edit command requires an <alias>")
+	}
+	if !explicitFlags["mac"] && !explicitFlags["iface"] && !explicitFlags["host"] {
+		return usageError("edit command requires at least one of --mac, --iface or --host")
+	}
+	host, err := hostFlag()
+	if err != nil {
+		return err
+	}
+
+	alias := args[0]
+	var updated aliases.MacIface
+	err = store.Edit(alias, func(mi *aliases.MacIface) error {
+		if explicitFlags["mac"] {
+			mac, warning, err := validateMAC(cliFlags.EditMac)
+			if err != nil {
+				return err
+			}
+			if len(warning) > 0 {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+			}
+			mi.Mac = mac
+		}
+		if explicitFlags["iface"] {
+			mi.Iface = cliFlags.EditIface
+		}
+		if explicitFlags["host"] {
+			mi.Host = host
+		}
+		updated = *mi
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-	return errors.New("remove command requires a <name> of an alias")
+	return writeOutput(os.Stdout, cliFlags.Output, aliasEntry{alias, updated.Mac, updated.Iface, updated.Host, ""})
 }
 
 // Run the wake command.
-func wakeCmd(args []string, aliases *Aliases) error {
+func wakeCmd(args []string, store aliases.Store) error {
 	if len(args) <= 0 {
-		return errors.New("No mac address specified to wake command")
+		// Let the user pick what to wake when there is one to ask.
+		if isInteractive() {
+			return pickCmd(args, store)
+		}
+		return usageError("No mac address specified to wake command")
 	}
 
-	// bcastInterface can be "eth0", "eth1", etc.. An empty string implies
-	// that we use the default interface when sending the UDP packet (nil).
-	bcastInterface := ""
-	macAddr := args[0]
+	// There is one result per MAC address of the alias, the structured
+	// outputs are a list even if it holds a single one. Aliases handled by a
+	// remote agent are woken through it instead.
+	results, remote, err := dispatchWake(args[0])
+	if !remote {
+		results, err = wakeInNetns(args[0], store, cliFlags.DryRun)
+		fireWakeWebhooks(cliFlags.DryRun, newWakeEvent(sourceCLI, wakeUser(), args[0], results, err))
+	}
+	res := wakeResults(results)
+
+	// The plain output only reports successes, errors are reported by the
+	// caller. Structured outputs always include the results.
+	if err == nil || cliFlags.Output != outputPlain || len(results) > 1 {
+		if oerr := writeOutput(os.Stdout, cliFlags.Output, res); oerr != nil {
+			return oerr
+		}
+	}
+	return err
+}
 
-	// First we need to see if this macAddr is actually an alias, if it is:
-	// we set the eth interface based on the stored item, and set the macAddr
-	// based on the alias of the entry.
-	mi, err := aliases.Get(macAddr)
-	if err == nil {
-		macAddr = mi.Mac
-		bcastInterface = mi.Iface
+// wakeInNetns wakes `target`. Everything from looking up the interfaces to
+// sending happens inside the network namespace, if one was given.
+func wakeInNetns(target string, store aliases.Store, dryRun bool) ([]wakeResult, error) {
+	if cliFlags.Netns == "" {
+		return wake(target, store, dryRun)
 	}
 
+	var results []wakeResult
+	err := inNetns(cliFlags.Netns, func() error {
+		var err error
+		results, err = wake(target, store, dryRun)
+		return err
+	})
+	return results, err
+}
+
+// wakePlan holds everything needed to send a magic packet once the target,
+// interface and broadcast address have been resolved.
+type wakePlan struct {
+	localMAC   wol.MACAddress
+	localAddr  *net.UDPAddr
+	device     string
+	remoteAddr *net.UDPAddr
+	packet     []byte
+}
+
+// planWake resolves one MAC/interface pair of `target` into a wakePlan
+// without sending anything. The returned result is filled in as far as we
+// got, even if an error is returned.
+func planWake(target string, mi aliases.MacIface) (wakeResult, *wakePlan, error) {
+	var err error
+	res := wakeResult{Target: target}
+
+	// bcastInterface can be "eth0", "eth1", etc.. An empty string implies
+	// that we use the default interface when sending the UDP packet (nil).
+	macAddr, bcastInterface := mi.Mac, mi.Iface
+
 	// Always use the interface specified in the command line, if it exists.
 	if cliFlags.BroadcastInterface != "" {
 		bcastInterface = cliFlags.BroadcastInterface
 	}
 
+	// The address to broadcast to is usually the default `255.255.255.255` but
+	// can be overloaded by specifying an override in the CLI arguments.
+	bcastIP := cliFlags.BroadcastIP
+
+	// If the address of the target is known, from the host of the alias or
+	// `--target-ip`, the routing table tells us which interface reaches it
+	// and the directed broadcast of its subnet. Anything set explicitly, in
+	// the config file too, still wins.
+	rt, routed, err := targetRoute(mi)
+	if err != nil {
+		return res, nil, err
+	}
+	if routed {
+		if bcastInterface == "" {
+			bcastInterface = rt.Iface
+		}
+		if bcast := rt.broadcast(); bcast != nil && optionSource("bcast") == "default" {
+			bcastIP = bcast.String()
+		}
+	}
+	bcastAddr := fmt.Sprintf("%s:%s", bcastIP, cliFlags.UDPPort)
+	res.Mac, res.Interface, res.Broadcast = macAddr, bcastInterface, bcastAddr
+
 	// Populate the local address in the event that the broadcast interface has
-	// been set.
-	var localAddr *net.UDPAddr
+	// been set. The interface can also be given as an IP address or CIDR, in
+	// which case the result reports the name of the interface it picked.
+	plan := &wakePlan{}
 	if bcastInterface != "" {
-		localAddr, err = ipFromInterface(bcastInterface)
-		if err != nil {
-			return err
+		ia, err := resolveInterface(bcastInterface)
+		switch {
+		case err == nil:
+			plan.localAddr = &net.UDPAddr{IP: ia.IPNet.IP}
+			res.Interface, res.Source = ia.Name, ia.IPNet.IP.String()
+
+		// Interfaces without an IPv4 address, like bridge members and VLAN
+		// sub-interfaces, can still be used by binding the socket to them.
+		case canBindToDevice && errors.Is(err, ErrNoInterfaceAddress):
+			ief, ierr := net.InterfaceByName(bcastInterface)
+			if ierr != nil {
+				return res, nil, err
+			}
+			ia = ifaceAddr{Name: ief.Name, HardwareAddr: ief.HardwareAddr}
+			plan.device, res.Interface, res.BindToDevice = ief.Name, ief.Name, true
+
+		default:
+			return res, nil, err
 		}
+
+		// The hardware address is only needed to build frames for a pcap
+		// file, so interfaces without one leave it zero.
+		copy(plan.localMAC[:], ia.HardwareAddr)
 	}
 
-	// The address to broadcast to is usually the default `255.255.255.255` but
-	// can be overloaded by specifying an override in the CLI arguments.
-	bcastAddr := fmt.Sprintf("%s:%s", cliFlags.BroadcastIP, cliFlags.UDPPort)
-	udpAddr, err := net.ResolveUDPAddr("udp", bcastAddr)
+	plan.remoteAddr, err = net.ResolveUDPAddr("udp", bcastAddr)
 	if err != nil {
-		return err
+		return res, nil, err
 	}
 
-	// Build the magic packet.
+	// Build the magic packet, and report the mac in its canonical form.
 	mp, err := wol.New(macAddr)
 	if err != nil {
-		return err
+		return res, nil, err
+	}
+	if addr, err := wol.ParseMAC(macAddr); err == nil {
+		res.Mac = addr.String()
 	}
 
 	// Grab a stream of bytes to send.
-	bs, err := mp.Marshal()
+	plan.packet, err = mp.Marshal()
 	if err != nil {
-		return err
+		return res, nil, err
 	}
+	return res, plan, nil
+}
 
-	// Grab a UDP connection to send our packet of bytes.
-	conn, err := net.DialUDP("udp", localAddr, udpAddr)
+// send transmits the planned magic packet and records the outcome in `res`.
+func (p *wakePlan) send(res *wakeResult) error {
+	// Grab a UDP connection to send our packet of bytes, bound to the
+	// interface itself when it has no address to bind to.
+	dialer := net.Dialer{}
+	if p.localAddr != nil {
+		dialer.LocalAddr = p.localAddr
+	}
+	if len(p.device) > 0 {
+		dialer.Control = bindToDevice(p.device)
+	}
+	conn, err := dialer.Dial("udp", p.remoteAddr.String())
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
 
-	fmt.Printf("Attempting to send a magic packet to MAC %s\n", macAddr)
-	fmt.Printf("... Broadcasting to: %s\n", bcastAddr)
-	n, err := conn.Write(bs)
-	if err == nil && n != 102 {
-		err = fmt.Errorf("magic packet sent was %d bytes (expected 102 bytes sent)", n)
+	res.Attempts++
+	n, err := conn.Write(p.packet)
+	res.BytesSent = n
+	if err == nil && n != wol.Size {
+		err = fmt.Errorf("%w: sent %d bytes (expected %d bytes sent)", wol.ErrShortWrite, n, wol.Size)
+	}
+	return err
+}
+
+// frame returns the planned magic packet as a complete Ethernet frame, either
+// wrapped in IPv4/UDP as it would be sent, or as a raw 0x0842 frame.
+func (p *wakePlan) frame(raw bool) ([]byte, error) {
+	if raw {
+		return wol.EthernetFrame(wol.BroadcastMAC, p.localMAC, wol.EtherTypeWOL, p.packet), nil
+	}
+	return wol.UDPFrame(wol.BroadcastMAC, p.localMAC, p.localAddr, p.remoteAddr, p.packet)
+}
+
+// writePcap writes the frames of all of the `plans` to a new pcap file at
+// `path`.
+func writePcap(path string, raw bool, plans []*wakePlan) error {
+	var frames [][]byte
+	for _, plan := range plans {
+		frame, err := plan.frame(raw)
+		if err != nil {
+			return err
+		}
+		frames = append(frames, frame)
 	}
+
+	fp, err := os.Create(path)
 	if err != nil {
 		return err
 	}
+	if err := wol.WritePcap(fp, time.Now(), frames...); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
+
+// wake sends a magic packet to every MAC address of `target`, which is either
+// an alias or a mac address. For a `dryRun` everything is resolved but
+// nothing is sent, and the packets are attached to the results instead. When
+// a pcap file is given the frames are written to it rather than sent.
+//
+// A failure for one MAC address does not stop the others from being woken.
+// The results are filled in as far as we got and carry their own error.
+func wake(target string, store aliases.Store, dryRun bool) ([]wakeResult, error) {
+	// First we need to see if the target is actually an alias, if it is: we
+	// use the mac addresses and interfaces stored for it.
+	alias, mis, err := resolveTarget(target, store, cliFlags.Fuzzy)
+	if err != nil {
+		return []wakeResult{{Target: target, Error: err.Error()}}, err
+	}
+
+	results := make([]wakeResult, len(mis))
+	errs := make([]error, len(mis))
+	var plans []*wakePlan
+	var planned []int
+	for i, mi := range mis {
+		var plan *wakePlan
+		results[i], plan, errs[i] = planWake(target, mi)
+		if errs[i] == nil {
+			plans, planned = append(plans, plan), append(planned, i)
+		}
+	}
+
+	if len(cliFlags.Pcap) > 0 && len(plans) > 0 {
+		err := writePcap(cliFlags.Pcap, cliFlags.Raw, plans)
+		for _, i := range planned {
+			results[i].Pcap, errs[i] = cliFlags.Pcap, err
+		}
+	}
+
+	for n, i := range planned {
+		switch {
+		case errs[i] != nil:
+		case dryRun:
+			results[i].DryRun = true
+			results[i].Packet = hex.EncodeToString(plans[n].packet)
+			results[i].packet = plans[n].packet
+		case len(cliFlags.Pcap) == 0:
+			errs[i] = plans[n].send(&results[i])
+		}
+	}
+
+	// Remember when a packet last went out to the alias, for the status
+	// command.
+	if len(alias) > 0 && len(lastWakePath) > 0 && sentAny(results) {
+		if err := recordWake(lastWakePath, alias, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not record the wake of %s: %v\n", alias, err)
+		}
+	}
+	return results, wakeError(results, errs)
+}
+
+// wakeError records the errors of waking each MAC address in `results` and
+// returns the overall error, if any.
+func wakeError(results []wakeResult, errs []error) error {
+	var first error
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			results[i].Error = err.Error()
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+	if failed == 0 || len(errs) == 1 {
+		return first
+	}
+	return fmt.Errorf("%d of %d magic packets could not be sent: %w", failed, len(errs), first)
+}
 
-	fmt.Printf("Magic packet sent successfully to %s\n", macAddr)
-	return nil
+// Run the config command.
+func configCmd(args []string, store aliases.Store) error {
+	if len(args) > 0 && strings.ToLower(args[0]) != "show" {
+		return usageError(fmt.Sprintf("unknown config subcommand %q (expected show)", args[0]))
+	}
+	return writeOutput(os.Stdout, cliFlags.Output, effectiveConfig)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 
-type cmdFnType func([]string, *Aliases) error
+type cmdFnType func([]string, aliases.Store) error
 
 var cmdMap = map[string]cmdFnType{
-	"alias":  aliasCmd,
-	"list":   listCmd,
-	"remove": removeCmd,
-	"wake":   wakeCmd,
+	"agent":      agentCmd,
+	"alias":      aliasCmd,
+	"check":      checkCmd,
+	"completion": completionCmd,
+	"config":     configCmd,
+	"copy":       copyCmd,
+	"edit":       editCmd,
+	"inspect":    inspectCmd,
+	"list":       listCmd,
+	"mqtt":       mqttCmd,
+	"pick":       pickCmd,
+	"remove":     removeCmd,
+	"rename":     renameCmd,
+	"server":     serverCmd,
+	"status":     statusCmd,
+	"wake":       wakeCmd,
+
+	// Hidden commands, not listed in the usage.
+	completeCmdName: completeCmd,
+}
+
+// readOnlyCmds never write to the store, so they open it read-only and can run
+// alongside each other (and alongside a long running wol holding the db).
+var readOnlyCmds = map[string]bool{
+	"agent":         true,
+	"check":         true,
+	"completion":    true,
+	"config":        true,
+	"inspect":       true,
+	"list":          true,
+	"mqtt":          true,
+	"pick":          true,
+	"status":        true,
+	"wake":          true,
+	completeCmdName: true,
+}
+
+// daemonCmds keep running, so they do not hold the store open but use
+// storeOpener whenever they need it.
+var daemonCmds = map[string]bool{
+	"agent":  true,
+	"mqtt":   true,
+	"server": true,
+	"status": true,
+}
+
+// noStoreCmds never look at the aliases, so the store is not opened for them.
+var noStoreCmds = map[string]bool{
+	"completion": true,
+	"config":     true,
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// newParser returns the cli parser for the options in `cliFlags`.
+func newParser() *flags.Parser {
+	return flags.NewParser(&cliFlags, flags.Default & ^flags.HelpFlag)
+}
+
 // Helper function to dump the usage and print an error if specified,
 // it also returns the exit code requested to the function (saves me a line).
 func printUsageGetExitCode(s string, e int) int {
@@ -211,10 +665,12 @@ func printUsageGetExitCode(s string, e int) int {
 	return e
 }
 
+// fatalOnError prints the error and exits with the exit code documented for
+// the kind of error (see errors.go).
 func fatalOnError(err error) {
 	if err != nil {
 		fmt.Printf("Fatal error: %s\n", err.Error())
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -223,26 +679,35 @@ func main() {
 	var args []string
 	var err error
 
-	// Parse arguments which might get passed to "wol".
-	parser := flags.NewParser(&cliFlags, flags.Default & ^flags.HelpFlag)
-	args, err = parser.Parse()
+	// Detect the current user to figure out what their ~ is.
+	usr, err := user.Current()
+	fatalOnError(err)
+
+	// Parse arguments which might get passed to "wol", then layer the env
+	// and config file defaults underneath them.
+	parser := newParser()
+	args, err = parseWithoutDefaults(parser, os.Args[1:])
+	if err == nil && !cliFlags.Help && !cliFlags.Version {
+		args, err = applyConfig(parser, os.Args[1:], args, usr.HomeDir)
+		fatalOnError(err)
+	}
 
 	// Disable color if needed.
 	if cliFlags.NoColor {
 		colorize.DisableColor = true
 	}
 
-	ec := 0
+	ec := exitOK
 	switch {
 
 	// Parse Error, print usage.
 	case err != nil:
 		fmt.Print(err.Error())
-		ec = printUsageGetExitCode("", 1)
+		ec = printUsageGetExitCode("", exitUsage)
 
 	// No arguments, or help requested, print usage.
 	case len(os.Args) == 1 || cliFlags.Help:
-		ec = printUsageGetExitCode("", 0)
+		ec = printUsageGetExitCode("", exitOK)
 
 	// "--version" requested.
 	case cliFlags.Version:
@@ -250,36 +715,55 @@ func main() {
 
 	// Make sure we are being asked to run a something.
 	case len(args) == 0:
-		ec = printUsageGetExitCode("No command specified, see usage:\n", 1)
+		ec = printUsageGetExitCode("No command specified, see usage:\n", exitUsage)
 
 	// All other cases go here.
 	case true:
-		// Detect the current user to figure out what their ~ is.
-		usr, err := user.Current()
-		fatalOnError(err)
+		// Allow the name for the `db` to be customized. The default depends
+		// on the store type, `bolt.db` for the bolt store.
+		dbName := cliFlags.DBName
+		if len(dbName) == 0 {
+			dbName = aliases.DefaultDBName(cliFlags.Store)
+		}
 
 		// If the user provided a `--db-dir` we expect an existing bolt db
-		// at the appropriate path.
-		dbDir := filepath.Join(usr.HomeDir, defaultDBDir)
+		// at the appropriate path, otherwise it goes in $XDG_DATA_HOME.
+		dbDir := dataDir(usr.HomeDir, dbName)
 		if len(cliFlags.DBDir) != 0 {
 			dbDir = cliFlags.DBDir
 		}
+		dbPath := filepath.Join(dbDir, dbName)
 
-		// Allow the name for the `db` to also be customized. Default is
-		// `bolt.db`.
-		dbPath := filepath.Join(dbDir, cliFlags.DBName)
+		// The time of the last wake of each alias is kept next to the db,
+		// stores without a file do not keep it.
+		if len(dbName) > 0 {
+			lastWakePath = filepath.Join(dbDir, lastWakeName)
+		}
 
-		// Load the list of aliases from the file at dbPath.
-		aliases, err := LoadAliases(dbPath)
-		fatalOnError(err)
-		defer aliases.Close()
+		// Fail early if we will not be able to print the result.
+		fatalOnError(checkOutputFormat(cliFlags.Output))
 
+		// Load the list of aliases from the file at dbPath, read-only unless
+		// the command changes it. Anything which is not a command is a wake.
 		cmd, cmdArgs := strings.ToLower(args[0]), args[1:]
-		if fn, ok := cmdMap[cmd]; ok {
-			err = fn(cmdArgs, aliases)
-		} else {
-			err = wakeCmd(args, aliases)
+		fn, ok := cmdMap[cmd]
+		if !ok {
+			fn, cmdArgs = wakeCmd, args
+		}
+
+		readOnly := !ok || readOnlyCmds[cmd]
+		storeOpener = func() (aliases.Store, error) {
+			return openAliasStore(dbPath, readOnly)
 		}
+
+		var store aliases.Store
+		if !daemonCmds[cmd] && !noStoreCmds[cmd] {
+			store, err = storeOpener()
+			fatalOnError(err)
+			defer store.Close()
+		}
+
+		err = fn(cmdArgs, store)
 		fatalOnError(err)
 	}
 	os.Exit(ec)
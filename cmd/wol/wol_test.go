@@ -42,3 +42,28 @@ func TestIPFromInterfaceNegative(t *testing.T) {
 		assert.NotNil(t, err)
 	}
 }
+
+func TestHostFlag(t *testing.T) {
+	saved := cliFlags
+	defer func() { cliFlags = saved }()
+
+	for _, tc := range []struct {
+		host string
+		ok   bool
+	}{
+		{"", true},
+		{"192.168.1.10", true},
+		{"nas.lan", true},
+		{"nas", true},
+		{"fe80::1", false},
+		{"nas.lan:22", false},
+		{"nas lan", false},
+	} {
+		cliFlags.Host = tc.host
+		host, err := hostFlag()
+		assert.Equal(t, tc.ok, err == nil, tc.host)
+		if tc.ok {
+			assert.Equal(t, tc.host, host)
+		}
+	}
+}
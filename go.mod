@@ -4,9 +4,11 @@ go 1.14
 
 require (
 	github.com/coreos/bbolt v1.3.1-coreos.6.0.20180223184059-4f5275f4ebbf
+	github.com/eclipse/paho.mqtt.golang v1.5.0
 	github.com/jessevdk/go-flags v0.0.0-20150816100521-1acbbaff2f34
 	github.com/mattn/go-colorable v0.1.11
+	github.com/mattn/go-isatty v0.0.14
 	github.com/sabhiram/go-colorize v0.0.0-20210403184538-366f55d711cf
 	github.com/stretchr/testify v0.0.0-20150929183540-2b15294402a8
-	golang.org/x/sys v0.0.0-20211103235746-7861aae1554b // indirect
+	golang.org/x/sys v0.22.0
 )
@@ -0,0 +1,53 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"fmt"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+var (
+	// ErrInvalidMAC is returned when a string is not a MAC address in any of
+	// the supported forms.
+	ErrInvalidMAC = errors.New("invalid MAC address")
+
+	// ErrUnsupportedLength is returned for well formed hardware addresses
+	// which are not 6 bytes long (EUI-64, InfiniBand, ...).
+	ErrUnsupportedLength = errors.New("unsupported MAC address length")
+
+	// ErrShortWrite is returned when fewer bytes than the size of a magic
+	// packet were sent.
+	ErrShortWrite = errors.New("short write of magic packet")
+
+	// ErrMalformedPacket is returned for payloads which start like a magic
+	// packet but do not follow the format.
+	ErrMalformedPacket = errors.New("malformed magic packet")
+
+	// ErrUnknownCaptureFormat is returned when reading a file which is neither
+	// a pcap nor a pcapng capture.
+	ErrUnknownCaptureFormat = errors.New("not a pcap or pcapng capture")
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// MACError describes a MAC address which could not be parsed. `Err` is either
+// ErrInvalidMAC or ErrUnsupportedLength.
+type MACError struct {
+	MAC string
+	Err error
+}
+
+func (e *MACError) Error() string {
+	if e.Err == ErrUnsupportedLength {
+		return fmt.Sprintf("%s is not a 6 byte IEEE 802 MAC-48 address", e.MAC)
+	}
+	return fmt.Sprintf("%s is not a IEEE 802 MAC-48 address", e.MAC)
+}
+
+// Unwrap allows errors.Is to match the underlying sentinel error.
+func (e *MACError) Unwrap() error {
+	return e.Err
+}
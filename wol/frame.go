@@ -0,0 +1,133 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	// EtherTypeIPv4 is the EtherType of an IPv4 packet.
+	EtherTypeIPv4 = 0x0800
+
+	// EtherTypeWOL is the EtherType used when a magic packet is sent directly
+	// as the payload of an Ethernet frame.
+	EtherTypeWOL = 0x0842
+
+	etherHeaderLen = 14
+	ipv4HeaderLen  = 20
+	udpHeaderLen   = 8
+	ipProtoUDP     = 17
+	defaultTTL     = 64
+)
+
+var (
+	// BroadcastMAC is the Ethernet broadcast address ff:ff:ff:ff:ff:ff.
+	BroadcastMAC = MACAddress{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// EthernetFrame returns an Ethernet II frame (without the trailing FCS) from
+// `src` to `dst` carrying `payload`.
+func EthernetFrame(dst, src MACAddress, etherType uint16, payload []byte) []byte {
+	frame := make([]byte, etherHeaderLen+len(payload))
+	copy(frame[0:6], dst[:])
+	copy(frame[6:12], src[:])
+	binary.BigEndian.PutUint16(frame[12:14], etherType)
+	copy(frame[etherHeaderLen:], payload)
+	return frame
+}
+
+// UDPFrame returns an Ethernet frame containing an IPv4 UDP datagram from
+// `src` to `dst` carrying `payload`. Both the IPv4 and UDP checksums are
+// filled in.
+func UDPFrame(dstMAC, srcMAC MACAddress, src, dst *net.UDPAddr, payload []byte) ([]byte, error) {
+	srcIP, dstIP := net.IPv4zero.To4(), dst.IP.To4()
+	if src != nil && src.IP != nil {
+		srcIP = src.IP.To4()
+	}
+	if srcIP == nil || dstIP == nil {
+		return nil, fmt.Errorf("udp frames can only be built for IPv4 addresses")
+	}
+	srcPort := 0
+	if src != nil {
+		srcPort = src.Port
+	}
+
+	udpLen := udpHeaderLen + len(payload)
+	packet := make([]byte, ipv4HeaderLen+udpLen)
+
+	// IPv4 header.
+	ip := packet[:ipv4HeaderLen]
+	ip[0] = 0x45 // Version 4, header length of 5 words.
+	binary.BigEndian.PutUint16(ip[2:4], uint16(len(packet)))
+	ip[8] = defaultTTL
+	ip[9] = ipProtoUDP
+	copy(ip[12:16], srcIP)
+	copy(ip[16:20], dstIP)
+	binary.BigEndian.PutUint16(ip[10:12], checksum(ip, 0))
+
+	// UDP header and payload, the checksum covers a pseudo header made up of
+	// the addresses, protocol and length.
+	udp := packet[ipv4HeaderLen:]
+	binary.BigEndian.PutUint16(udp[0:2], uint16(srcPort))
+	binary.BigEndian.PutUint16(udp[2:4], uint16(dst.Port))
+	binary.BigEndian.PutUint16(udp[4:6], uint16(udpLen))
+	copy(udp[udpHeaderLen:], payload)
+
+	pseudo := uint32(ipProtoUDP) + uint32(udpLen)
+	for i := 0; i < 4; i += 2 {
+		pseudo += uint32(binary.BigEndian.Uint16(srcIP[i:]))
+		pseudo += uint32(binary.BigEndian.Uint16(dstIP[i:]))
+	}
+	sum := checksum(udp, pseudo)
+	if sum == 0 {
+		// A zero checksum means "no checksum" for UDP over IPv4.
+		sum = 0xFFFF
+	}
+	binary.BigEndian.PutUint16(udp[6:8], sum)
+
+	return EthernetFrame(dstMAC, srcMAC, EtherTypeIPv4, packet), nil
+}
+
+// checksum returns the internet checksum (RFC 1071) of `bs`, seeded with an
+// initial partial `sum`.
+func checksum(bs []byte, sum uint32) uint16 {
+	for i := 0; i+1 < len(bs); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(bs[i:]))
+	}
+	if len(bs)%2 == 1 {
+		sum += uint32(bs[len(bs)-1]) << 8
+	}
+	for sum > 0xFFFF {
+		sum = (sum >> 16) + (sum & 0xFFFF)
+	}
+	return ^uint16(sum)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// RawFrame returns the magic packet as the payload of an Ethernet broadcast
+// frame with EtherType 0x0842.
+func (mp *MagicPacket) RawFrame(src MACAddress) ([]byte, error) {
+	bs, err := mp.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return EthernetFrame(BroadcastMAC, src, EtherTypeWOL, bs), nil
+}
+
+// UDPFrame returns the magic packet as the payload of a UDP datagram from
+// `src` to `dst`, wrapped in an Ethernet broadcast frame.
+func (mp *MagicPacket) UDPFrame(srcMAC MACAddress, src, dst *net.UDPAddr) ([]byte, error) {
+	bs, err := mp.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return UDPFrame(BroadcastMAC, srcMAC, src, dst, bs)
+}
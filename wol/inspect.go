@@ -0,0 +1,209 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	etherTypeIPv6 = 0x86DD
+	etherTypeVLAN = 0x8100
+	sllHeaderLen  = 16
+	ipv6HeaderLen = 40
+)
+
+var (
+	// wolPorts are the UDP ports magic packets are conventionally sent to.
+	// Payloads on these ports are reported even if they are malformed.
+	wolPorts = map[uint16]bool{0: true, 7: true, 9: true}
+
+	syncStream = []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ParseMagicPacket parses a magic packet from `bs`, returning the target MAC
+// address and the SecureOn password (if any). The packet must consist of the
+// 6 byte sync stream, 16 repetitions of the target and optionally a 4 or 6
+// byte password.
+func ParseMagicPacket(bs []byte) (MACAddress, []byte, error) {
+	var mac MACAddress
+	if len(bs) < Size {
+		return mac, nil, fmt.Errorf("%w: %d bytes is too short", ErrMalformedPacket, len(bs))
+	}
+	if !bytes.Equal(bs[:6], syncStream) {
+		return mac, nil, fmt.Errorf("%w: missing sync stream", ErrMalformedPacket)
+	}
+
+	copy(mac[:], bs[6:12])
+	for i := 1; i < 16; i++ {
+		if !bytes.Equal(bs[6+6*i:12+6*i], mac[:]) {
+			return mac, nil, fmt.Errorf("%w: repetition %d of the MAC address differs", ErrMalformedPacket, i+1)
+		}
+	}
+
+	switch password := bs[Size:]; len(password) {
+	case 0:
+		return mac, nil, nil
+	case 4, 6:
+		return mac, password, nil
+	default:
+		return mac, nil, fmt.Errorf("%w: %d trailing bytes is not a SecureOn password", ErrMalformedPacket, len(password))
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// CapturedPacket describes a magic packet found in a captured frame. If the
+// packet is malformed, Err is set and Target may not be meaningful.
+type CapturedPacket struct {
+	Time        time.Time
+	Transport   string // "udp" or "ethernet".
+	Source      string // "ip:port" for UDP, otherwise a MAC address.
+	Destination string
+	Target      MACAddress
+	Password    []byte
+	Err         error
+}
+
+// InspectFrame looks for a magic packet in `frame`, which was captured on a
+// link of type `linkType`. It returns false if the frame does not contain a
+// magic packet, not even a malformed one.
+func InspectFrame(linkType uint32, frame []byte) (CapturedPacket, bool) {
+	var cp CapturedPacket
+	var etherType uint16
+	var payload []byte
+
+	switch linkType {
+	case LinkTypeEthernet:
+		if len(frame) < etherHeaderLen {
+			return cp, false
+		}
+		cp.Destination = net.HardwareAddr(frame[0:6]).String()
+		cp.Source = net.HardwareAddr(frame[6:12]).String()
+		etherType, payload = binary.BigEndian.Uint16(frame[12:14]), frame[etherHeaderLen:]
+		if etherType == etherTypeVLAN && len(payload) >= 4 {
+			etherType, payload = binary.BigEndian.Uint16(payload[2:4]), payload[4:]
+		}
+	case LinkTypeLinuxSLL:
+		// The cooked header only records the source address.
+		if len(frame) < sllHeaderLen {
+			return cp, false
+		}
+		if n := int(binary.BigEndian.Uint16(frame[4:6])); n == 6 {
+			cp.Source = net.HardwareAddr(frame[6:12]).String()
+		}
+		etherType, payload = binary.BigEndian.Uint16(frame[14:16]), frame[sllHeaderLen:]
+	default:
+		return cp, false
+	}
+
+	switch etherType {
+	case EtherTypeWOL:
+		cp.Transport = "ethernet"
+		cp.Target, cp.Password, cp.Err = ParseMagicPacket(payload)
+		return cp, true
+
+	case EtherTypeIPv4, etherTypeIPv6:
+		src, dst, data, ok := udpPayload(etherType, payload)
+		if !ok {
+			return cp, false
+		}
+
+		// The sync stream may be preceded by other data, in which case the
+		// magic packet starts at the sync stream. Anything else is only of
+		// interest if it was sent to one of the usual ports.
+		idx := bytes.Index(data, syncStream)
+		if idx < 0 && !wolPorts[uint16(dst.Port)] {
+			return cp, false
+		}
+		if idx > 0 {
+			data = data[idx:]
+		}
+
+		cp.Transport = "udp"
+		cp.Source, cp.Destination = src.String(), dst.String()
+		cp.Target, cp.Password, cp.Err = ParseMagicPacket(data)
+		return cp, true
+	}
+	return cp, false
+}
+
+// udpPayload returns the addresses and payload of a UDP datagram carried in
+// an IPv4 or IPv6 `packet`.
+func udpPayload(etherType uint16, packet []byte) (*net.UDPAddr, *net.UDPAddr, []byte, bool) {
+	var srcIP, dstIP net.IP
+	var udp []byte
+
+	if etherType == EtherTypeIPv4 {
+		if len(packet) < ipv4HeaderLen || packet[0]>>4 != 4 {
+			return nil, nil, nil, false
+		}
+		ihl := int(packet[0]&0x0F) * 4
+		fragment := binary.BigEndian.Uint16(packet[6:8]) & 0x1FFF
+		if packet[9] != ipProtoUDP || fragment != 0 || ihl < ipv4HeaderLen || len(packet) < ihl {
+			return nil, nil, nil, false
+		}
+		srcIP, dstIP, udp = net.IP(packet[12:16]), net.IP(packet[16:20]), packet[ihl:]
+		if total := int(binary.BigEndian.Uint16(packet[2:4])); total >= ihl && total <= len(packet) {
+			udp = packet[ihl:total]
+		}
+	} else {
+		// Extension headers are not followed, UDP must be the next header.
+		if len(packet) < ipv6HeaderLen || packet[0]>>4 != 6 || packet[6] != ipProtoUDP {
+			return nil, nil, nil, false
+		}
+		srcIP, dstIP, udp = net.IP(packet[8:24]), net.IP(packet[24:40]), packet[ipv6HeaderLen:]
+	}
+
+	if len(udp) < udpHeaderLen {
+		return nil, nil, nil, false
+	}
+	src := &net.UDPAddr{IP: srcIP, Port: int(binary.BigEndian.Uint16(udp[0:2]))}
+	dst := &net.UDPAddr{IP: dstIP, Port: int(binary.BigEndian.Uint16(udp[2:4]))}
+	if n := int(binary.BigEndian.Uint16(udp[4:6])); n >= udpHeaderLen && n <= len(udp) {
+		udp = udp[:n]
+	}
+	return src, dst, udp[udpHeaderLen:], true
+}
+
+// InspectCapture reads a pcap or pcapng capture from `r` and returns all of
+// the magic packets found in it, including malformed ones.
+func InspectCapture(r io.Reader) ([]CapturedPacket, error) {
+	pr, err := NewPcapReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var packets []CapturedPacket
+	for {
+		frame, err := pr.Next()
+		if err == io.EOF {
+			return packets, nil
+		}
+		if err != nil {
+			return packets, err
+		}
+		if cp, ok := InspectFrame(frame.LinkType, frame.Data); ok {
+			cp.Time = frame.Time
+			packets = append(packets, cp)
+		}
+	}
+}
+
+// FormatPassword returns a SecureOn password in the usual notation, dotted
+// decimal for 4 bytes and colon separated hex for 6 bytes.
+func FormatPassword(password []byte) string {
+	if len(password) == 4 {
+		return net.IP(password).String()
+	}
+	return net.HardwareAddr(password).String()
+}
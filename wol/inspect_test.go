@@ -0,0 +1,229 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// testPacket returns a magic packet for `testTarget` followed by `password`.
+func testPacket(t *testing.T, password ...byte) []byte {
+	mp, err := New(testTarget.String())
+	assert.Nil(t, err)
+	bs, err := mp.Marshal()
+	assert.Nil(t, err)
+	return append(bs, password...)
+}
+
+// testUDPFrame returns an ethernet frame with `payload` sent to port `port`.
+func testUDPFrame(t *testing.T, port int, payload []byte) []byte {
+	src := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000}
+	dst := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 255), Port: port}
+	frame, err := UDPFrame(BroadcastMAC, testSrcMAC, src, dst, payload)
+	assert.Nil(t, err)
+	return frame
+}
+
+// testPcapng builds a little endian pcapng file with a single interface using
+// the given timestamp resolution, holding one enhanced packet per frame.
+func testPcapng(tsresol byte, ticks uint64, frames ...[]byte) []byte {
+	opts := []byte{pcapngOptTSResol, 0, 1, 0, tsresol, 0, 0, 0, 0, 0, 0, 0}
+	return testPcapngOpts(opts, ticks, frames...)
+}
+
+// testPcapngOpts is testPcapng with the raw options of the interface, which
+// are not padded.
+func testPcapngOpts(opts []byte, ticks uint64, frames ...[]byte) []byte {
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	rawBlock := func(kind uint32, body []byte) {
+		total := uint32(12 + len(body))
+		binary.Write(&buf, le, kind)
+		binary.Write(&buf, le, total)
+		buf.Write(body)
+		binary.Write(&buf, le, total)
+	}
+	block := func(kind uint32, body []byte) {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		rawBlock(kind, body)
+	}
+
+	shb := make([]byte, 16)
+	le.PutUint32(shb[0:4], pcapngByteOrderMagic)
+	le.PutUint16(shb[4:6], 1)
+	le.PutUint64(shb[8:16], ^uint64(0))
+	block(pcapngSectionHeader, shb)
+
+	idb := make([]byte, 8, 20)
+	le.PutUint16(idb[0:2], LinkTypeEthernet)
+	rawBlock(pcapngInterface, append(idb, opts...))
+
+	// Blocks of an unknown type are skipped.
+	block(0x0BAD, []byte{1, 2, 3, 4})
+
+	for _, frame := range frames {
+		epb := make([]byte, 20)
+		le.PutUint32(epb[4:8], uint32(ticks>>32))
+		le.PutUint32(epb[8:12], uint32(ticks))
+		le.PutUint32(epb[12:16], uint32(len(frame)))
+		le.PutUint32(epb[16:20], uint32(len(frame)))
+		block(pcapngEnhancedPacket, append(epb, frame...))
+	}
+	return buf.Bytes()
+}
+
+func TestParseMagicPacket(t *testing.T) {
+	corrupt := testPacket(t)
+	corrupt[50] ^= 0xFF
+
+	for _, tc := range []struct {
+		bs       []byte
+		password []byte
+		ok       bool
+	}{
+		{testPacket(t), nil, true},
+		{testPacket(t, 1, 2, 3, 4), []byte{1, 2, 3, 4}, true},
+		{testPacket(t, 1, 2, 3, 4, 5, 6), []byte{1, 2, 3, 4, 5, 6}, true},
+		{testPacket(t, 1, 2), nil, false},
+		{testPacket(t)[:101], nil, false},
+		{append([]byte{0}, testPacket(t)[1:]...), nil, false},
+		{corrupt, nil, false},
+	} {
+		mac, password, err := ParseMagicPacket(tc.bs)
+		if tc.ok {
+			assert.Nil(t, err)
+			assert.Equal(t, testTarget, mac)
+			assert.Equal(t, tc.password, password)
+		} else {
+			assert.True(t, errors.Is(err, ErrMalformedPacket))
+		}
+	}
+}
+
+func TestInspectCapture(t *testing.T) {
+	ts := time.Unix(1500000000, 250000000).UTC()
+	raw := EthernetFrame(BroadcastMAC, testSrcMAC, EtherTypeWOL, testPacket(t, 192, 168, 0, 1))
+
+	var buf bytes.Buffer
+	assert.Nil(t, WritePcap(&buf, ts,
+		testUDPFrame(t, 9, testPacket(t)),
+		testUDPFrame(t, 53, []byte("not a magic packet")),
+		testUDPFrame(t, 40000, append([]byte("prefix"), testPacket(t)...)),
+		testUDPFrame(t, 7, testPacket(t)[:60]),
+		raw,
+	))
+
+	packets, err := InspectCapture(&buf)
+	assert.Nil(t, err)
+	assert.Len(t, packets, 4)
+
+	assert.Equal(t, ts, packets[0].Time)
+	assert.Equal(t, "udp", packets[0].Transport)
+	assert.Equal(t, "10.0.0.1:5000", packets[0].Source)
+	assert.Equal(t, "10.0.0.255:9", packets[0].Destination)
+	assert.Equal(t, testTarget, packets[0].Target)
+	assert.Nil(t, packets[0].Err)
+
+	assert.Equal(t, "10.0.0.255:40000", packets[1].Destination)
+	assert.Nil(t, packets[1].Err)
+
+	assert.Equal(t, "10.0.0.255:7", packets[2].Destination)
+	assert.True(t, errors.Is(packets[2].Err, ErrMalformedPacket))
+
+	assert.Equal(t, "ethernet", packets[3].Transport)
+	assert.Equal(t, testSrcMAC.String(), packets[3].Source)
+	assert.Equal(t, "ff:ff:ff:ff:ff:ff", packets[3].Destination)
+	assert.Equal(t, testTarget, packets[3].Target)
+	assert.Equal(t, "192.168.0.1", FormatPassword(packets[3].Password))
+}
+
+func TestInspectPcapng(t *testing.T) {
+	frame := testUDPFrame(t, 9, testPacket(t, 1, 2, 3, 4, 5, 6))
+	for _, tc := range []struct {
+		tsresol byte
+		ticks   uint64
+	}{
+		{6, 1500000000123456},
+		{9, 1500000000123456000},
+		{0x80 | 20, 1500000000<<20 | 1<<19},
+	} {
+		packets, err := InspectCapture(bytes.NewReader(testPcapng(tc.tsresol, tc.ticks, frame, frame)))
+		assert.Nil(t, err)
+		assert.Len(t, packets, 2)
+		assert.Equal(t, int64(1500000000), packets[0].Time.Unix())
+		assert.True(t, packets[0].Time.Nanosecond() > 0)
+		assert.Equal(t, testTarget, packets[1].Target)
+		assert.Equal(t, "01:02:03:04:05:06", FormatPassword(packets[1].Password))
+	}
+
+	// The padding of the last option may be missing.
+	packets, err := InspectCapture(bytes.NewReader(testPcapngOpts(
+		[]byte{pcapngOptTSResol, 0, 1, 0, 9}, 1500000000123456000, frame)))
+	assert.Nil(t, err)
+	assert.Len(t, packets, 1)
+	assert.Equal(t, time.Unix(1500000000, 123456000).UTC(), packets[0].Time)
+
+	for _, opts := range [][]byte{
+		{pcapngOptTSResol, 0, 1, 0, 0x40, 0, 0, 0},
+		{pcapngOptTSResol, 0, 1, 0, 0x80 | 0x40, 0, 0, 0},
+		{pcapngOptTSResol, 0, 1, 0, 19, 0, 0, 0},
+		{pcapngOptTSResol, 0, 1, 0},
+		{pcapngOptTSResol, 0, 8, 0, 1, 2, 3, 4},
+	} {
+		_, err := InspectCapture(bytes.NewReader(testPcapngOpts(opts, 0, frame)))
+		assert.NotNil(t, err)
+	}
+}
+
+func TestPcapReaderFormats(t *testing.T) {
+	frame := testUDPFrame(t, 9, testPacket(t))
+
+	// Big endian with nanosecond timestamps.
+	var buf bytes.Buffer
+	be := binary.BigEndian
+	for _, v := range []interface{}{
+		uint32(pcapMagicNano), uint16(2), uint16(4), int32(0), uint32(0), uint32(65535), uint32(LinkTypeEthernet),
+		uint32(1500000000), uint32(123456789), uint32(len(frame)), uint32(len(frame)),
+	} {
+		binary.Write(&buf, be, v)
+	}
+	buf.Write(frame)
+
+	pr, err := NewPcapReader(&buf)
+	assert.Nil(t, err)
+	cf, err := pr.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, time.Unix(1500000000, 123456789).UTC(), cf.Time)
+	assert.Equal(t, frame, cf.Data)
+	_, err = pr.Next()
+	assert.NotNil(t, err)
+
+	// Linux cooked captures carry the ethertype after a 16 byte header.
+	sll := make([]byte, 16)
+	be.PutUint16(sll[4:6], 6)
+	copy(sll[6:12], testSrcMAC[:])
+	be.PutUint16(sll[14:16], EtherTypeIPv4)
+	cp, ok := InspectFrame(LinkTypeLinuxSLL, append(sll, frame[14:]...))
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.1:5000", cp.Source)
+	assert.Equal(t, testTarget, cp.Target)
+
+	cp, ok = InspectFrame(LinkTypeLinuxSLL, append(sll[:14], 0x08, 0x42))
+	assert.True(t, ok)
+	assert.Equal(t, testSrcMAC.String(), cp.Source)
+	assert.NotNil(t, cp.Err)
+
+	_, err = NewPcapReader(bytes.NewReader([]byte("definitely not a capture")))
+	assert.True(t, errors.Is(err, ErrUnknownCaptureFormat))
+}
@@ -5,16 +5,28 @@ package wol
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"regexp"
+	"strconv"
+	"strings"
 )
 
 ////////////////////////////////////////////////////////////////////////////////
 
+const (
+	// Size is the length in bytes of a marshalled magic packet.
+	Size = 102
+)
+
 var (
-	delims = ":-"
-	reMAC  = regexp.MustCompile(`^([0-9a-fA-F]{2}[` + delims + `]){5}([0-9a-fA-F]{2})$`)
+	// Delimiters which may separate the 6 octets of a MAC address.
+	delims = ":- "
+
+	// Cisco style dotted (0123.4567.89ab) and bare (0123456789ab) forms.
+	reDottedMAC = regexp.MustCompile(`^[0-9a-fA-F]{4}\.[0-9a-fA-F]{4}\.[0-9a-fA-F]{4}$`)
+	reBareMAC   = regexp.MustCompile(`^[0-9a-fA-F]{12}$`)
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -29,25 +41,118 @@ type MagicPacket struct {
 	payload [16]MACAddress
 }
 
-// New returns a magic packet based on a mac address string.
-func New(mac string) (*MagicPacket, error) {
-	var packet MagicPacket
+// ParseMAC parses a 6 byte IEEE 802 MAC-48 address string into a MACAddress.
+// The following forms are accepted (in upper or lower case):
+//
+//	01:23:45:67:89:ab    01-23-45-67-89-ab    01 23 45 67 89 ab
+//	1:23:45:67:89:ab     1-2-3-4-5-6          0123.4567.89ab
+//	0123456789ab
+//
+// Octets separated by a delimiter may omit their leading zero, but the same
+// delimiter must be used throughout.
+func ParseMAC(mac string) (MACAddress, error) {
 	var macAddr MACAddress
 
-	hwAddr, err := net.ParseMAC(mac)
-	if err != nil {
-		return nil, err
+	invalid := &MACError{MAC: mac, Err: ErrInvalidMAC}
+
+	s := strings.TrimSpace(mac)
+	switch {
+	case reBareMAC.MatchString(s):
+		hex.Decode(macAddr[:], []byte(s))
+		return macAddr, nil
+	case reDottedMAC.MatchString(s):
+		hex.Decode(macAddr[:], []byte(strings.Replace(s, ".", "", -1)))
+		return macAddr, nil
+	case strings.Contains(s, "."):
+		if _, err := net.ParseMAC(s); err == nil {
+			return macAddr, &MACError{MAC: mac, Err: ErrUnsupportedLength}
+		}
+		return macAddr, invalid
 	}
 
-	// We only support 6 byte MAC addresses since it is much harder to use the
-	// binary.Write(...) interface when the size of the MagicPacket is dynamic.
-	if !reMAC.MatchString(mac) {
-		return nil, fmt.Errorf("%s is not a IEEE 802 MAC-48 address", mac)
+	// Otherwise the octets are separated by one of the delimiters, use the
+	// first one we find to split the rest.
+	idx := strings.IndexAny(s, delims)
+	if idx < 0 {
+		return macAddr, invalid
+	}
+	octets := strings.Split(s, s[idx:idx+1])
+	if len(octets) != len(macAddr) {
+		// We only support 6 byte MAC addresses since it is much harder to use
+		// the binary.Write(...) interface when the size of the MagicPacket is
+		// dynamic. Let the caller know if this was a valid, longer address.
+		if _, err := net.ParseMAC(s); err == nil {
+			return macAddr, &MACError{MAC: mac, Err: ErrUnsupportedLength}
+		}
+		return macAddr, invalid
 	}
+	for i, o := range octets {
+		if len(o) < 1 || len(o) > 2 {
+			return macAddr, invalid
+		}
+		b, err := strconv.ParseUint(o, 16, 8)
+		if err != nil {
+			return macAddr, invalid
+		}
+		macAddr[i] = byte(b)
+	}
+	return macAddr, nil
+}
 
-	// Copy bytes from the returned HardwareAddr -> a fixed size MACAddress.
-	for idx := range macAddr {
-		macAddr[idx] = hwAddr[idx]
+// String returns the canonical form of the address: lower case octets
+// separated by colons.
+func (m MACAddress) String() string {
+	return fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", m[0], m[1], m[2], m[3], m[4], m[5])
+}
+
+// MarshalText implements encoding.TextMarshaler using the canonical form.
+func (m MACAddress) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting any of the
+// forms supported by ParseMAC.
+func (m *MACAddress) UnmarshalText(text []byte) error {
+	addr, err := ParseMAC(string(text))
+	if err != nil {
+		return err
+	}
+	*m = addr
+	return nil
+}
+
+// IsZero returns true if this is the all-zero address 00:00:00:00:00:00.
+func (m MACAddress) IsZero() bool {
+	return m == MACAddress{}
+}
+
+// IsBroadcast returns true if this is the broadcast address ff:ff:ff:ff:ff:ff.
+func (m MACAddress) IsBroadcast() bool {
+	return m == MACAddress{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+}
+
+// IsMulticast returns true if the group bit of the first octet is set. Note
+// that the broadcast address is also a multicast address.
+func (m MACAddress) IsMulticast() bool {
+	return m[0]&0x01 != 0
+}
+
+// IsLocallyAdministered returns true if the address was assigned locally
+// (rather than by the manufacturer), which is often the case for randomized
+// or virtual interfaces.
+func (m MACAddress) IsLocallyAdministered() bool {
+	return m[0]&0x02 != 0
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// New returns a magic packet based on a mac address string.
+func New(mac string) (*MagicPacket, error) {
+	var packet MagicPacket
+
+	macAddr, err := ParseMAC(mac)
+	if err != nil {
+		return nil, err
 	}
 
 	// Setup the header which is 6 repetitions of 0xFF.
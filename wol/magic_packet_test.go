@@ -3,6 +3,9 @@ package wol
 ////////////////////////////////////////////////////////////////////////////////
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -35,12 +38,19 @@ func TestNewMagicPacketNegative(t *testing.T) {
 		mac string
 	}{
 		{"00x00:00:00:00:00"},
+		{"00:00-00:00:00:00"},
+		{"000:00:00:00:00:0"},
+		{"00:00:00:00:00:"},
+		{"00:00:00:00:00"},
+		{"0123456789a"},
+		{"0123456789abc"},
+		{"01234.567.89ab"},
+		{""},
 		{"00:00:Z0:00:00:00"},
 		{"01:23:45:67:89:ab:cd:ef"},
 		{"01:23:45:67:89:ab:cd:ef:00:00:01:23:45:67:89:ab:cd:ef:00:00"},
 		{"01-23-45-67-89-ab-cd-ef"},
 		{"01-23-45-67-89-ab-cd-ef-00-00-01-23-45-67-89-ab-cd-ef-00-00"},
-		{"0123.4567.89ab"},
 		{"0123.4567.89ab.cdef"},
 		{"0123.4567.89ab.cdef.0000.0123.4567.89ab.cdef.0000"},
 	} {
@@ -67,3 +77,89 @@ func TestMagicPacketMarshal(t *testing.T) {
 		assert.Equal(t, len(bs), tc.count)
 	}
 }
+
+func TestParseMAC(t *testing.T) {
+	expected := MACAddress{0x01, 0x23, 0x45, 0x67, 0x89, 0xab}
+	for _, tc := range []struct {
+		mac      string
+		expected MACAddress
+	}{
+		{"01:23:45:67:89:ab", expected},
+		{"01-23-45-67-89-AB", expected},
+		{"01 23 45 67 89 ab", expected},
+		{"0123.4567.89ab", expected},
+		{"0123.4567.89AB", expected},
+		{"0123456789ab", expected},
+		{" 01:23:45:67:89:ab ", expected},
+		{"1:23:45:67:89:ab", expected},
+		{"1-2-3-4-5-6", MACAddress{1, 2, 3, 4, 5, 6}},
+		{"1 2 3 4 5 6", MACAddress{1, 2, 3, 4, 5, 6}},
+		{"00-FF-01-03-0A-0b", MACAddress{0, 255, 1, 3, 10, 11}},
+	} {
+		mac, err := ParseMAC(tc.mac)
+		assert.Nil(t, err, tc.mac)
+		assert.Equal(t, tc.expected, mac, tc.mac)
+
+		// New must accept exactly the same forms.
+		_, err = New(tc.mac)
+		assert.Nil(t, err, tc.mac)
+	}
+
+	_, err := ParseMAC("01:23:45:67:89:ab:cd:ef")
+	assert.NotNil(t, err)
+}
+
+func TestMACAddressText(t *testing.T) {
+	mac := MACAddress{0x01, 0x23, 0x45, 0x67, 0x89, 0xab}
+	assert.Equal(t, "01:23:45:67:89:ab", mac.String())
+	assert.Equal(t, "01:23:45:67:89:ab", fmt.Sprint(mac))
+
+	bs, err := json.Marshal(struct{ Mac MACAddress }{mac})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"Mac":"01:23:45:67:89:ab"}`, string(bs))
+
+	var out struct{ Mac MACAddress }
+	assert.Nil(t, json.Unmarshal([]byte(`{"Mac":"0123.4567.89AB"}`), &out))
+	assert.Equal(t, mac, out.Mac)
+
+	assert.NotNil(t, json.Unmarshal([]byte(`{"Mac":"foobar"}`), &out))
+}
+
+func TestMACAddressClass(t *testing.T) {
+	for _, tc := range []struct {
+		mac                                    MACAddress
+		zero, broadcast, multicast, localAdmin bool
+	}{
+		{MACAddress{0, 0, 0, 0, 0, 0}, true, false, false, false},
+		{MACAddress{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, false, true, true, true},
+		{MACAddress{0x01, 0x00, 0x5e, 0, 0, 1}, false, false, true, false},
+		{MACAddress{0x02, 0x42, 0xac, 0x11, 0, 2}, false, false, false, true},
+		{MACAddress{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}, false, false, false, false},
+	} {
+		assert.Equal(t, tc.zero, tc.mac.IsZero())
+		assert.Equal(t, tc.broadcast, tc.mac.IsBroadcast())
+		assert.Equal(t, tc.multicast, tc.mac.IsMulticast())
+		assert.Equal(t, tc.localAdmin, tc.mac.IsLocallyAdministered())
+	}
+}
+
+func TestParseMACErrors(t *testing.T) {
+	for _, tc := range []struct {
+		mac      string
+		expected error
+	}{
+		{"foobar", ErrInvalidMAC},
+		{"00:00:Z0:00:00:00", ErrInvalidMAC},
+		{"01:23:45:67:89", ErrInvalidMAC},
+		{"01:23:45:67:89:ab:cd:ef", ErrUnsupportedLength},
+		{"01-23-45-67-89-ab-cd-ef", ErrUnsupportedLength},
+		{"0123.4567.89ab.cdef", ErrUnsupportedLength},
+	} {
+		_, err := ParseMAC(tc.mac)
+		assert.True(t, errors.Is(err, tc.expected), tc.mac)
+
+		var macErr *MACError
+		assert.True(t, errors.As(err, &macErr), tc.mac)
+		assert.Equal(t, tc.mac, macErr.MAC)
+	}
+}
@@ -0,0 +1,371 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/bits"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	// pcapMagic identifies a libpcap file with microsecond timestamps.
+	pcapMagic        = 0xa1b2c3d4
+	pcapVersionMajor = 2
+	pcapVersionMinor = 4
+	pcapSnapLen      = 65535
+
+	// LinkTypeEthernet is the pcap link type for Ethernet frames.
+	LinkTypeEthernet = 1
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// PcapWriter writes frames to a stream in the classic libpcap file format,
+// which can be opened in Wireshark or replayed with tcpreplay.
+type PcapWriter struct {
+	w io.Writer
+}
+
+// NewPcapWriter writes the pcap file header to `w` and returns a PcapWriter
+// which can be used to append Ethernet frames to it.
+func NewPcapWriter(w io.Writer) (*PcapWriter, error) {
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:4], pcapMagic)
+	binary.LittleEndian.PutUint16(hdr[4:6], pcapVersionMajor)
+	binary.LittleEndian.PutUint16(hdr[6:8], pcapVersionMinor)
+	// Bytes 8:16 are the timezone offset and timestamp accuracy, both zero.
+	binary.LittleEndian.PutUint32(hdr[16:20], pcapSnapLen)
+	binary.LittleEndian.PutUint32(hdr[20:24], LinkTypeEthernet)
+
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return &PcapWriter{w: w}, nil
+}
+
+// WriteFrame appends a single frame captured at time `ts`.
+func (pw *PcapWriter) WriteFrame(ts time.Time, frame []byte) error {
+	hdr := make([]byte, 16)
+	binary.LittleEndian.PutUint32(hdr[0:4], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(hdr[4:8], uint32(ts.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(hdr[8:12], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(hdr[12:16], uint32(len(frame)))
+
+	if _, err := pw.w.Write(hdr); err != nil {
+		return err
+	}
+	_, err := pw.w.Write(frame)
+	return err
+}
+
+// WritePcap writes a complete pcap file containing `frames` to `w`, all
+// stamped with the time `ts`.
+func WritePcap(w io.Writer, ts time.Time, frames ...[]byte) error {
+	pw, err := NewPcapWriter(w)
+	if err != nil {
+		return err
+	}
+	for _, frame := range frames {
+		if err := pw.WriteFrame(ts, frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	// pcapMagicNano identifies a libpcap file with nanosecond timestamps.
+	pcapMagicNano = 0xa1b23c4d
+
+	// LinkTypeLinuxSLL is the pcap link type for captures made on the Linux
+	// "any" device.
+	LinkTypeLinuxSLL = 113
+
+	// pcapng block types and the byte order magic of the section header.
+	pcapngSectionHeader  = 0x0A0D0D0A
+	pcapngInterface      = 0x00000001
+	pcapngSimplePacket   = 0x00000003
+	pcapngEnhancedPacket = 0x00000006
+	pcapngByteOrderMagic = 0x1A2B3C4D
+	pcapngOptTSResol     = 9
+
+	// maxBlockLen bounds the size of a single record so that a corrupt file
+	// can not make us allocate arbitrary amounts of memory.
+	maxBlockLen = 16 << 20
+)
+
+// CapturedFrame is a single frame read from a capture file.
+type CapturedFrame struct {
+	Time     time.Time
+	LinkType uint32
+	Data     []byte
+}
+
+// pcapngIface holds what we need to know about a pcapng interface.
+type pcapngIface struct {
+	linkType uint32
+	tsPerSec uint64
+}
+
+// PcapReader reads frames from a pcap or pcapng capture.
+type PcapReader struct {
+	r      io.Reader
+	ng     bool
+	order  binary.ByteOrder
+	nano   bool
+	link   uint32
+	ifaces []pcapngIface
+}
+
+// NewPcapReader reads the file header from `r` and returns a reader for the
+// frames in it. Both classic pcap (either byte order, micro or nanosecond
+// timestamps) and pcapng files are supported.
+func NewPcapReader(r io.Reader) (*PcapReader, error) {
+	hdr := make([]byte, 4)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, ErrUnknownCaptureFormat
+	}
+
+	pr := &PcapReader{r: r}
+	if binary.LittleEndian.Uint32(hdr) == pcapngSectionHeader {
+		pr.ng = true
+		return pr, pr.readSectionHeader()
+	}
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(hdr) {
+		case pcapMagic:
+			pr.order = order
+		case pcapMagicNano:
+			pr.order, pr.nano = order, true
+		}
+	}
+	if pr.order == nil {
+		return nil, ErrUnknownCaptureFormat
+	}
+
+	rest := make([]byte, 20)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, err
+	}
+	pr.link = pr.order.Uint32(rest[16:20])
+	return pr, nil
+}
+
+// Next returns the next frame in the capture, or io.EOF once there are no
+// more.
+func (pr *PcapReader) Next() (CapturedFrame, error) {
+	if pr.ng {
+		return pr.nextBlock()
+	}
+
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(pr.r, hdr); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return CapturedFrame{}, err
+	}
+
+	sec, frac := pr.order.Uint32(hdr[0:4]), pr.order.Uint32(hdr[4:8])
+	capLen := pr.order.Uint32(hdr[8:12])
+	if capLen > maxBlockLen {
+		return CapturedFrame{}, fmt.Errorf("pcap record of %d bytes is too large", capLen)
+	}
+	if !pr.nano {
+		frac *= 1000
+	}
+
+	data := make([]byte, capLen)
+	if _, err := io.ReadFull(pr.r, data); err != nil {
+		return CapturedFrame{}, err
+	}
+	return CapturedFrame{
+		Time:     time.Unix(int64(sec), int64(frac)).UTC(),
+		LinkType: pr.link,
+		Data:     data,
+	}, nil
+}
+
+// readBlock reads the remainder of a pcapng block whose type has already been
+// consumed, returning the block body without the trailing length.
+func (pr *PcapReader) readBlock() ([]byte, error) {
+	bs := make([]byte, 4)
+	if _, err := io.ReadFull(pr.r, bs); err != nil {
+		return nil, err
+	}
+	total := pr.order.Uint32(bs)
+	if total < 12 || total > maxBlockLen {
+		return nil, fmt.Errorf("invalid pcapng block length %d", total)
+	}
+
+	body := make([]byte, total-8)
+	if _, err := io.ReadFull(pr.r, body); err != nil {
+		return nil, err
+	}
+	return body[:len(body)-4], nil
+}
+
+// readSectionHeader reads a pcapng section header block, which determines the
+// byte order of everything up to the next section.
+func (pr *PcapReader) readSectionHeader() error {
+	bs := make([]byte, 8)
+	if _, err := io.ReadFull(pr.r, bs); err != nil {
+		return err
+	}
+	switch {
+	case binary.LittleEndian.Uint32(bs[4:8]) == pcapngByteOrderMagic:
+		pr.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(bs[4:8]) == pcapngByteOrderMagic:
+		pr.order = binary.BigEndian
+	default:
+		return ErrUnknownCaptureFormat
+	}
+
+	total := pr.order.Uint32(bs[0:4])
+	if total < 28 || total > maxBlockLen {
+		return fmt.Errorf("invalid pcapng section header length %d", total)
+	}
+	_, err := io.CopyN(ioutil.Discard, pr.r, int64(total-12))
+	pr.ifaces = nil
+	return err
+}
+
+// nextBlock reads pcapng blocks until it finds one containing a frame.
+func (pr *PcapReader) nextBlock() (CapturedFrame, error) {
+	for {
+		bs := make([]byte, 4)
+		if _, err := io.ReadFull(pr.r, bs); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return CapturedFrame{}, err
+		}
+
+		if binary.LittleEndian.Uint32(bs) == pcapngSectionHeader {
+			if err := pr.readSectionHeader(); err != nil {
+				return CapturedFrame{}, err
+			}
+			continue
+		}
+
+		body, err := pr.readBlock()
+		if err != nil {
+			return CapturedFrame{}, err
+		}
+
+		switch pr.order.Uint32(bs) {
+		case pcapngInterface:
+			if len(body) < 8 {
+				return CapturedFrame{}, fmt.Errorf("short pcapng interface block")
+			}
+			iface, err := pr.parseInterface(body)
+			if err != nil {
+				return CapturedFrame{}, err
+			}
+			pr.ifaces = append(pr.ifaces, iface)
+
+		case pcapngEnhancedPacket:
+			if len(body) < 20 {
+				return CapturedFrame{}, fmt.Errorf("short pcapng packet block")
+			}
+			id := pr.order.Uint32(body[0:4])
+			if int(id) >= len(pr.ifaces) {
+				return CapturedFrame{}, fmt.Errorf("pcapng packet for unknown interface %d", id)
+			}
+			iface := pr.ifaces[id]
+			ticks := uint64(pr.order.Uint32(body[4:8]))<<32 | uint64(pr.order.Uint32(body[8:12]))
+			capLen := pr.order.Uint32(body[12:16])
+			if int(capLen) > len(body)-20 {
+				return CapturedFrame{}, fmt.Errorf("pcapng packet block is truncated")
+			}
+			return CapturedFrame{
+				Time:     iface.time(ticks),
+				LinkType: iface.linkType,
+				Data:     body[20 : 20+capLen],
+			}, nil
+
+		case pcapngSimplePacket:
+			// Simple packets have no timestamp and belong to the first
+			// interface, their captured length is bounded by the block.
+			if len(body) < 4 || len(pr.ifaces) == 0 {
+				return CapturedFrame{}, fmt.Errorf("invalid pcapng simple packet block")
+			}
+			n := int(pr.order.Uint32(body[0:4]))
+			if n > len(body)-4 {
+				n = len(body) - 4
+			}
+			return CapturedFrame{LinkType: pr.ifaces[0].linkType, Data: body[4 : 4+n]}, nil
+		}
+		// Any other block type carries no frames and is skipped.
+	}
+}
+
+// parseInterface returns the link type and timestamp resolution described by
+// a pcapng interface description block.
+func (pr *PcapReader) parseInterface(body []byte) (pcapngIface, error) {
+	iface := pcapngIface{
+		linkType: uint32(pr.order.Uint16(body[0:2])),
+		tsPerSec: uint64(time.Second / time.Microsecond),
+	}
+
+	opts := body[8:]
+	for len(opts) >= 4 {
+		code, n := pr.order.Uint16(opts[0:2]), int(pr.order.Uint16(opts[2:4]))
+		if code == 0 {
+			break
+		}
+		if 4+n > len(opts) {
+			return pcapngIface{}, fmt.Errorf("pcapng interface options are truncated")
+		}
+		if code == pcapngOptTSResol && n == 1 {
+			if iface.tsPerSec = tsResolution(opts[4]); iface.tsPerSec == 0 {
+				return pcapngIface{}, fmt.Errorf("invalid pcapng timestamp resolution 0x%02x", opts[4])
+			}
+		}
+		// The padding of the last option may be missing.
+		if 4+(n+3)&^3 > len(opts) {
+			break
+		}
+		opts = opts[4+(n+3)&^3:]
+	}
+	return iface, nil
+}
+
+// maxTSPerSec is the finest timestamp resolution accepted, the 64 bit
+// timestamps of any finer one overflow within seconds.
+const maxTSPerSec = 1e18
+
+// tsResolution decodes the if_tsresol option into the number of timestamp
+// ticks per second, or 0 if it is out of range.
+func tsResolution(v byte) uint64 {
+	if v&0x80 != 0 {
+		if v&0x7F >= 64 || uint64(1)<<uint(v&0x7F) > maxTSPerSec {
+			return 0
+		}
+		return uint64(1) << uint(v&0x7F)
+	}
+	ticks := uint64(1)
+	for i := byte(0); i < v; i++ {
+		if ticks *= 10; ticks > maxTSPerSec {
+			return 0
+		}
+	}
+	return ticks
+}
+
+// time converts a pcapng timestamp in ticks to a time.
+func (iface pcapngIface) time(ticks uint64) time.Time {
+	sec, rem := ticks/iface.tsPerSec, ticks%iface.tsPerSec
+	hi, lo := bits.Mul64(rem, uint64(time.Second))
+	nsec, _ := bits.Div64(hi, lo, iface.tsPerSec)
+	return time.Unix(int64(sec), int64(nsec)).UTC()
+}
@@ -0,0 +1,108 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+var (
+	testSrcMAC = MACAddress{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	testTarget = MACAddress{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+)
+
+func TestRawFrame(t *testing.T) {
+	mp, err := New(testTarget.String())
+	assert.Nil(t, err)
+
+	frame, err := mp.RawFrame(testSrcMAC)
+	assert.Nil(t, err)
+	assert.Equal(t, 14+Size, len(frame))
+	assert.Equal(t, BroadcastMAC[:], frame[0:6])
+	assert.Equal(t, testSrcMAC[:], frame[6:12])
+	assert.Equal(t, uint16(EtherTypeWOL), binary.BigEndian.Uint16(frame[12:14]))
+
+	bs, err := mp.Marshal()
+	assert.Nil(t, err)
+	assert.Equal(t, bs, frame[14:])
+}
+
+func TestUDPFrame(t *testing.T) {
+	mp, err := New(testTarget.String())
+	assert.Nil(t, err)
+
+	src := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 40000}
+	dst := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 255), Port: 9}
+	frame, err := mp.UDPFrame(testSrcMAC, src, dst)
+	assert.Nil(t, err)
+	assert.Equal(t, 14+20+8+Size, len(frame))
+	assert.Equal(t, uint16(EtherTypeIPv4), binary.BigEndian.Uint16(frame[12:14]))
+
+	// IPv4 header, a valid checksum sums to zero.
+	ip := frame[14:34]
+	assert.Equal(t, byte(0x45), ip[0])
+	assert.Equal(t, uint16(20+8+Size), binary.BigEndian.Uint16(ip[2:4]))
+	assert.Equal(t, byte(17), ip[9])
+	assert.Equal(t, []byte{192, 168, 1, 10}, ip[12:16])
+	assert.Equal(t, []byte{192, 168, 1, 255}, ip[16:20])
+	assert.Equal(t, uint16(0), checksum(ip, 0))
+
+	// UDP header, verified against the pseudo header.
+	udp := frame[34:]
+	assert.Equal(t, uint16(40000), binary.BigEndian.Uint16(udp[0:2]))
+	assert.Equal(t, uint16(9), binary.BigEndian.Uint16(udp[2:4]))
+	assert.Equal(t, uint16(8+Size), binary.BigEndian.Uint16(udp[4:6]))
+	pseudo := uint32(17) + uint32(8+Size) + 0xC0A8 + 0x010A + 0xC0A8 + 0x01FF
+	assert.Equal(t, uint16(0), checksum(udp, pseudo))
+
+	bs, err := mp.Marshal()
+	assert.Nil(t, err)
+	assert.Equal(t, bs, udp[8:])
+
+	// Without a source the frame is sent from 0.0.0.0.
+	frame, err = mp.UDPFrame(testSrcMAC, nil, dst)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 0}, frame[26:30])
+
+	// Only IPv4 is supported.
+	_, err = mp.UDPFrame(testSrcMAC, nil, &net.UDPAddr{IP: net.ParseIP("ff02::1"), Port: 9})
+	assert.NotNil(t, err)
+}
+
+func TestWritePcap(t *testing.T) {
+	ts := time.Unix(1500000000, 123456000)
+	frames := [][]byte{{1, 2, 3}, {4, 5, 6, 7}}
+
+	var buf bytes.Buffer
+	assert.Nil(t, WritePcap(&buf, ts, frames...))
+	bs := buf.Bytes()
+	assert.Equal(t, 24+(16+3)+(16+4), len(bs))
+
+	// Global header.
+	le := binary.LittleEndian
+	assert.Equal(t, uint32(0xa1b2c3d4), le.Uint32(bs[0:4]))
+	assert.Equal(t, uint16(2), le.Uint16(bs[4:6]))
+	assert.Equal(t, uint16(4), le.Uint16(bs[6:8]))
+	assert.Equal(t, uint32(65535), le.Uint32(bs[16:20]))
+	assert.Equal(t, uint32(LinkTypeEthernet), le.Uint32(bs[20:24]))
+
+	// Records.
+	off := 24
+	for _, frame := range frames {
+		rec := bs[off : off+16]
+		assert.Equal(t, uint32(1500000000), le.Uint32(rec[0:4]))
+		assert.Equal(t, uint32(123456), le.Uint32(rec[4:8]))
+		assert.Equal(t, uint32(len(frame)), le.Uint32(rec[8:12]))
+		assert.Equal(t, uint32(len(frame)), le.Uint32(rec[12:16]))
+		assert.Equal(t, frame, bs[off+16:off+16+len(frame)])
+		off += 16 + len(frame)
+	}
+}